@@ -0,0 +1,405 @@
+package radixtree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// binaryMagic and binaryVersion are put at the beginning of the output
+// of MarshalBinary.
+//
+// The format after them is the nodes in depth-first pre-order.
+// Each node is encoded as follows:
+//
+//	label length (uvarint) and label
+//	flags (1 byte, flagHasValue is set if the node has a value)
+//	value length (uvarint) and value encoded by ValueCodec, if the node has a value
+//	child count (uvarint)
+//
+// The label of the root node is always empty.
+const (
+	binaryMagic   = "RDXT"
+	binaryVersion = 1
+)
+
+const flagHasValue = 1
+
+// ErrInvalidBinary is returned when decoding malformed binary data.
+var ErrInvalidBinary = errors.New("radixtree: invalid binary data")
+
+// ValueCodec encodes and decodes values in a radix tree for
+// MarshalBinary and UnmarshalBinary.
+type ValueCodec interface {
+	// AppendValue appends the encoded value to buf and returns
+	// the extended buffer.
+	AppendValue(buf []byte, value interface{}) ([]byte, error)
+
+	// DecodeValue decodes a value encoded by AppendValue.
+	// It must not retain data after it returns.
+	DecodeValue(data []byte) (interface{}, error)
+}
+
+// SetValueCodec sets the codec for values used in MarshalBinary and
+// UnmarshalBinary. DefaultValueCodec is used if you do not call this
+// or pass nil.
+func (t *Tree) SetValueCodec(c ValueCodec) {
+	t.codec = c
+}
+
+func (t *Tree) valueCodec() ValueCodec {
+	if t.codec == nil {
+		return DefaultValueCodec
+	}
+	return t.codec
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+// The output preserves the structure of nodes, so UnmarshalBinary
+// does not need to split labels as Set does.
+func (t *Tree) MarshalBinary() ([]byte, error) {
+	buf := append([]byte(binaryMagic), binaryVersion)
+	return t.root.appendBinary(buf, t.valueCodec())
+}
+
+func (n *node) appendBinary(buf []byte, codec ValueCodec) ([]byte, error) {
+	buf, err := n.appendBinaryHeader(buf, codec)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range n.children {
+		buf, err = child.appendBinary(buf, codec)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// appendBinaryHeader appends the encoded node without its children.
+func (n *node) appendBinaryHeader(buf []byte, codec ValueCodec) ([]byte, error) {
+	buf = binary.AppendUvarint(buf, uint64(len(n.label)))
+	buf = append(buf, n.label...)
+	if n.hasValue() {
+		buf = append(buf, flagHasValue)
+		v, err := codec.AppendValue(nil, n.value)
+		if err != nil {
+			return nil, err
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(v)))
+		buf = append(buf, v...)
+	} else {
+		buf = append(buf, 0)
+	}
+	return binary.AppendUvarint(buf, uint64(len(n.children))), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+// It replaces all keys in the radix tree with the ones in data.
+func (t *Tree) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, []byte(binaryMagic)) {
+		return fmt.Errorf("%w: bad magic", ErrInvalidBinary)
+	}
+	data = data[len(binaryMagic):]
+	if len(data) == 0 {
+		return fmt.Errorf("%w: missing version", ErrInvalidBinary)
+	}
+	if data[0] != binaryVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidBinary, data[0])
+	}
+	r := bytes.NewReader(data[1:])
+	d := binaryDecoder{r: r, codec: t.valueCodec()}
+	root, err := d.decodeNode(true)
+	if err != nil {
+		return err
+	}
+	if r.Len() > 0 {
+		return fmt.Errorf("%w: %d trailing bytes", ErrInvalidBinary, r.Len())
+	}
+	t.replaceRoot(root)
+	return nil
+}
+
+// binaryReader is the reader for binaryDecoder.
+type binaryReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// binaryDecoder decodes nodes written by appendBinaryHeader in
+// depth-first pre-order.
+type binaryDecoder struct {
+	r     binaryReader
+	codec ValueCodec
+	buf   []byte
+}
+
+// maxChildren is the maximum number of children, since labels of
+// children start with distinct bytes.
+const maxChildren = 256
+
+// eofError converts io.EOF in the middle of a node to an error.
+func (d *binaryDecoder) eofError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: unexpected end of data", ErrInvalidBinary)
+	}
+	return err
+}
+
+func (d *binaryDecoder) uvarint() (uint64, error) {
+	var x uint64
+	var s uint
+	for i := 0; i < binary.MaxVarintLen64; i++ {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return 0, d.eofError(err)
+		}
+		if b < 0x80 {
+			if i == binary.MaxVarintLen64-1 && b > 1 {
+				break
+			}
+			return x | uint64(b)<<s, nil
+		}
+		x |= uint64(b&0x7f) << s
+		s += 7
+	}
+	return 0, fmt.Errorf("%w: uvarint overflows", ErrInvalidBinary)
+}
+
+// bytes reads a length-prefixed byte slice. The returned slice is
+// valid until the next call of bytes unless alloc is true.
+func (d *binaryDecoder) bytes(alloc bool) ([]byte, error) {
+	l, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	// Avoid allocating a large buffer for a corrupted length before
+	// actually reading the data.
+	const chunkSize = 4096
+	var b []byte
+	if !alloc {
+		b = d.buf[:0]
+	}
+	for l > 0 {
+		n := l
+		if n > chunkSize {
+			n = chunkSize
+		}
+		off := len(b)
+		b = append(b, make([]byte, n)...)
+		if _, err := io.ReadFull(d.r, b[off:]); err != nil {
+			return nil, d.eofError(err)
+		}
+		l -= n
+	}
+	if !alloc {
+		d.buf = b
+	}
+	return b, nil
+}
+
+func (d *binaryDecoder) decodeNode(isRoot bool) (*node, error) {
+	label, err := d.bytes(true)
+	if err != nil {
+		return nil, err
+	}
+	flags, err := d.r.ReadByte()
+	if err != nil {
+		return nil, d.eofError(err)
+	}
+	n := &node{value: noValue}
+	if flags&flagHasValue != 0 {
+		v, err := d.bytes(false)
+		if err != nil {
+			return nil, err
+		}
+		n.value, err = d.codec.DecodeValue(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	childCount, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if childCount > maxChildren {
+		return nil, fmt.Errorf("%w: too many children", ErrInvalidBinary)
+	}
+	if childCount > 0 {
+		n.children = make([]*node, childCount)
+	}
+	for i := range n.children {
+		child, err := d.decodeNode(false)
+		if err != nil {
+			return nil, err
+		}
+		n.children[i] = child
+	}
+	if err := n.validate(label, isRoot); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBinary, err)
+	}
+	if len(label) > 0 {
+		n.label = label
+	}
+	return n, nil
+}
+
+// validate checks n satisfies invariants of the radix tree which
+// Set, Delete and DeleteSubtree keep. label is the label of n, which
+// may not have been set to n yet.
+func (n *node) validate(label []byte, isRoot bool) error {
+	if isRoot {
+		if len(label) != 0 {
+			return errors.New("root with label")
+		}
+	} else {
+		if len(label) == 0 {
+			return errors.New("empty label")
+		}
+		if !n.hasValue() && len(n.children) < 2 {
+			return fmt.Errorf("node %q without value has %d children", label, len(n.children))
+		}
+	}
+	for i := 1; i < len(n.children); i++ {
+		if n.children[i-1].label[0] >= n.children[i].label[0] {
+			return fmt.Errorf("unsorted children of node %q", label)
+		}
+	}
+	return nil
+}
+
+// DefaultValueCodec is the ValueCodec used when no codec is set with
+// SetValueCodec. It supports nil and values of types bool, int, int8,
+// int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32,
+// float64, string and []byte. Other types cause an error.
+var DefaultValueCodec ValueCodec = basicValueCodec{}
+
+type basicValueCodec struct{}
+
+const (
+	basicNil byte = iota
+	basicBool
+	basicInt
+	basicInt8
+	basicInt16
+	basicInt32
+	basicInt64
+	basicUint
+	basicUint8
+	basicUint16
+	basicUint32
+	basicUint64
+	basicFloat32
+	basicFloat64
+	basicString
+	basicBytes
+)
+
+func (basicValueCodec) AppendValue(buf []byte, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(buf, basicNil), nil
+	case bool:
+		if v {
+			return append(buf, basicBool, 1), nil
+		}
+		return append(buf, basicBool, 0), nil
+	case int:
+		return binary.AppendVarint(append(buf, basicInt), int64(v)), nil
+	case int8:
+		return binary.AppendVarint(append(buf, basicInt8), int64(v)), nil
+	case int16:
+		return binary.AppendVarint(append(buf, basicInt16), int64(v)), nil
+	case int32:
+		return binary.AppendVarint(append(buf, basicInt32), int64(v)), nil
+	case int64:
+		return binary.AppendVarint(append(buf, basicInt64), v), nil
+	case uint:
+		return binary.AppendUvarint(append(buf, basicUint), uint64(v)), nil
+	case uint8:
+		return binary.AppendUvarint(append(buf, basicUint8), uint64(v)), nil
+	case uint16:
+		return binary.AppendUvarint(append(buf, basicUint16), uint64(v)), nil
+	case uint32:
+		return binary.AppendUvarint(append(buf, basicUint32), uint64(v)), nil
+	case uint64:
+		return binary.AppendUvarint(append(buf, basicUint64), v), nil
+	case float32:
+		return binary.LittleEndian.AppendUint32(append(buf, basicFloat32), math.Float32bits(v)), nil
+	case float64:
+		return binary.LittleEndian.AppendUint64(append(buf, basicFloat64), math.Float64bits(v)), nil
+	case string:
+		return append(append(buf, basicString), v...), nil
+	case []byte:
+		return append(append(buf, basicBytes), v...), nil
+	default:
+		return nil, fmt.Errorf("radixtree: unsupported value type %T for DefaultValueCodec", value)
+	}
+}
+
+func (basicValueCodec) DecodeValue(data []byte) (interface{}, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: empty value", ErrInvalidBinary)
+	}
+	typ, data := data[0], data[1:]
+	switch typ {
+	case basicNil:
+		return nil, nil
+	case basicBool:
+		if len(data) != 1 {
+			return nil, fmt.Errorf("%w: bad bool value", ErrInvalidBinary)
+		}
+		return data[0] != 0, nil
+	case basicInt, basicInt8, basicInt16, basicInt32, basicInt64:
+		v, n := binary.Varint(data)
+		if n != len(data) || n <= 0 {
+			return nil, fmt.Errorf("%w: bad int value", ErrInvalidBinary)
+		}
+		switch typ {
+		case basicInt:
+			return int(v), nil
+		case basicInt8:
+			return int8(v), nil
+		case basicInt16:
+			return int16(v), nil
+		case basicInt32:
+			return int32(v), nil
+		}
+		return v, nil
+	case basicUint, basicUint8, basicUint16, basicUint32, basicUint64:
+		v, n := binary.Uvarint(data)
+		if n != len(data) || n <= 0 {
+			return nil, fmt.Errorf("%w: bad uint value", ErrInvalidBinary)
+		}
+		switch typ {
+		case basicUint:
+			return uint(v), nil
+		case basicUint8:
+			return uint8(v), nil
+		case basicUint16:
+			return uint16(v), nil
+		case basicUint32:
+			return uint32(v), nil
+		}
+		return v, nil
+	case basicFloat32:
+		if len(data) != 4 {
+			return nil, fmt.Errorf("%w: bad float32 value", ErrInvalidBinary)
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(data)), nil
+	case basicFloat64:
+		if len(data) != 8 {
+			return nil, fmt.Errorf("%w: bad float64 value", ErrInvalidBinary)
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+	case basicString:
+		return string(data), nil
+	case basicBytes:
+		return append([]byte{}, data...), nil
+	default:
+		return nil, fmt.Errorf("%w: unknown value type %d", ErrInvalidBinary, typ)
+	}
+}
@@ -1,6 +1,19 @@
 package radixtree_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/hnakamur/radixtree"
@@ -173,6 +186,8 @@ func TestGet(t *testing.T) {
 		{tree: tree1, key: []byte("test"), value: 2, exists: true},
 		{tree: tree1, key: []byte("water"), value: 3, exists: true},
 		{tree: tree1, key: []byte("tea"), exists: false},
+		{tree: tree1, key: []byte("te"), exists: false},
+		{tree: radixtree.New(), key: []byte{}, exists: false},
 	}
 	for i, c := range testCases {
 		value, exists := c.tree.Get(c.key)
@@ -216,6 +231,54 @@ func TestDelete(t *testing.T) {
 			result: ".\n" +
 				"`-- \"tea\" 1 int\n",
 		},
+		{
+			tree: func() *radixtree.Tree {
+				t := radixtree.New()
+				t.Set([]byte("tea"), 1)
+				return t
+			}(),
+			key:     []byte("te"),
+			deleted: false,
+			result: ".\n" +
+				"`-- \"tea\" 1 int\n",
+		},
+		{
+			tree: func() *radixtree.Tree {
+				t := radixtree.New()
+				t.Set([]byte("tea"), 1)
+				return t
+			}(),
+			key:     []byte("tx"),
+			deleted: false,
+			result: ".\n" +
+				"`-- \"tea\" 1 int\n",
+		},
+		{
+			tree: func() *radixtree.Tree {
+				t := radixtree.New()
+				t.Set([]byte("team"), 1)
+				t.Set([]byte("tear"), 2)
+				return t
+			}(),
+			key:     []byte("tea"),
+			deleted: false,
+			result: ".\n" +
+				"`-- \"tea\"\n" +
+				"   |-- \"m\" 1 int\n" +
+				"   `-- \"r\" 2 int\n",
+		},
+		{
+			tree: func() *radixtree.Tree {
+				t := radixtree.New()
+				t.Set([]byte{}, 0)
+				t.Set([]byte("tea"), 1)
+				return t
+			}(),
+			key:     []byte{},
+			deleted: true,
+			result: ".\n" +
+				"`-- \"tea\" 1 int\n",
+		},
 		{
 			tree: func() *radixtree.Tree {
 				t := radixtree.New()
@@ -389,6 +452,40 @@ func TestDeleteSubtree(t *testing.T) {
 			result: ".\n" +
 				"`-- \"tea\" 1 int\n",
 		},
+		{
+			tree: func() *radixtree.Tree {
+				t := radixtree.New()
+				t.Set([]byte("tea"), 1)
+				return t
+			}(),
+			prefix:  []byte("teb"),
+			deleted: false,
+			result: ".\n" +
+				"`-- \"tea\" 1 int\n",
+		},
+		{
+			tree: func() *radixtree.Tree {
+				t := radixtree.New()
+				t.Set([]byte("tea"), 1)
+				return t
+			}(),
+			prefix:  []byte("tx"),
+			deleted: false,
+			result: ".\n" +
+				"`-- \"tea\" 1 int\n",
+		},
+		{
+			tree: func() *radixtree.Tree {
+				t := radixtree.New()
+				t.Set([]byte{}, 0)
+				t.Set([]byte("tea"), 1)
+				t.Set([]byte("water"), 2)
+				return t
+			}(),
+			prefix:  []byte{},
+			deleted: true,
+			result:  ".\n",
+		},
 		{
 			tree: func() *radixtree.Tree {
 				t := radixtree.New()
@@ -541,3 +638,1562 @@ func TestDeleteSubtree(t *testing.T) {
 		}
 	}
 }
+
+func TestWatch(t *testing.T) {
+	type event struct {
+		watcher string
+		op      radixtree.Op
+		key     string
+	}
+	tree := radixtree.New()
+	var got []event
+	watch := func(name, prefix string) func() {
+		return tree.Watch([]byte(prefix), func(op radixtree.Op, key []byte) {
+			got = append(got, event{watcher: name, op: op, key: string(key)})
+		})
+	}
+	watch("all", "")
+	cancelX := watch("x", "service/x/")
+	watch("xdb", "service/x/db/")
+	watch("y", "service/y/")
+
+	check := func(want []event) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("events unmatch, got=%v, want=%v", got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("event unmatch, index=%d, got=%v, want=%v", i, got[i], want[i])
+			}
+		}
+		got = nil
+	}
+
+	tree.Set([]byte("service/x/db/primary"), 1)
+	check([]event{
+		{"all", radixtree.OpSet, "service/x/db/primary"},
+		{"x", radixtree.OpSet, "service/x/db/primary"},
+		{"xdb", radixtree.OpSet, "service/x/db/primary"},
+	})
+
+	tree.Set([]byte("service/y/port"), 2)
+	check([]event{
+		{"all", radixtree.OpSet, "service/y/port"},
+		{"y", radixtree.OpSet, "service/y/port"},
+	})
+
+	tree.Delete([]byte("service/x/nosuchkey"))
+	check(nil)
+
+	tree.Delete([]byte("service/y/port"))
+	check([]event{
+		{"all", radixtree.OpDelete, "service/y/port"},
+		{"y", radixtree.OpDelete, "service/y/port"},
+	})
+
+	tree.DeleteSubtree([]byte("service/"))
+	check([]event{
+		{"all", radixtree.OpDeleteSubtree, "service/"},
+		{"x", radixtree.OpDeleteSubtree, "service/"},
+		{"xdb", radixtree.OpDeleteSubtree, "service/"},
+	})
+
+	cancelX()
+	cancelX()
+	tree.Set([]byte("service/x/db/primary"), 3)
+	check([]event{
+		{"all", radixtree.OpSet, "service/x/db/primary"},
+		{"xdb", radixtree.OpSet, "service/x/db/primary"},
+	})
+}
+
+type recordingObserver struct {
+	events []string
+}
+
+func (o *recordingObserver) OnSet(key []byte, oldValue, newValue interface{}) {
+	o.events = append(o.events, fmt.Sprintf("set %q %v %v", key, oldValue, newValue))
+}
+
+func (o *recordingObserver) OnDelete(key []byte, oldValue interface{}) {
+	o.events = append(o.events, fmt.Sprintf("delete %q %v", key, oldValue))
+}
+
+func (o *recordingObserver) OnDeleteSubtree(prefix []byte, removed int) {
+	o.events = append(o.events, fmt.Sprintf("deleteSubtree %q %d", prefix, removed))
+}
+
+func TestObserver(t *testing.T) {
+	tree := radixtree.New()
+	o := &recordingObserver{}
+	tree.SetObserver(o)
+
+	tree.Set([]byte("tea"), 1)
+	tree.Set([]byte("tea"), 2)
+	tree.Set([]byte("team"), 3)
+	tree.Set([]byte("tear"), 4)
+	tree.Set([]byte("water"), 5)
+	tree.Delete([]byte("te"))
+	tree.Delete([]byte("tea"))
+	tree.DeleteSubtree([]byte("test"))
+	tree.DeleteSubtree([]byte("te"))
+	tree.SetObserver(nil)
+	tree.Delete([]byte("water"))
+
+	want := []string{
+		`set "tea" <nil> 1`,
+		`set "tea" 1 2`,
+		`set "team" <nil> 3`,
+		`set "tear" <nil> 4`,
+		`set "water" <nil> 5`,
+		`delete "tea" 2`,
+		`deleteSubtree "te" 2`,
+	}
+	if len(o.events) != len(want) {
+		t.Fatalf("events unmatch, got=%q, want=%q", o.events, want)
+	}
+	for i := range want {
+		if o.events[i] != want[i] {
+			t.Errorf("event unmatch, index=%d, got=%s, want=%s", i, o.events[i], want[i])
+		}
+	}
+}
+
+func TestMarshalBinary(t *testing.T) {
+	tree := radixtree.New()
+	tree.Set([]byte{}, "root")
+	tree.Set([]byte("tea"), 1)
+	tree.Set([]byte("team"), int64(-2))
+	tree.Set([]byte("tear"), []byte("bytes"))
+	tree.Set([]byte("test"), 3.5)
+	tree.Set([]byte("water"), nil)
+	tree.Set([]byte("wine"), true)
+	data, err := tree.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := radixtree.New()
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != tree.String() {
+		t.Errorf("result unmatch, got=\n%s, want=\n%s", got.String(), tree.String())
+	}
+
+	for i := 0; i < len(data); i++ {
+		if err := radixtree.New().UnmarshalBinary(data[:i]); err == nil {
+			t.Errorf("no error for truncated data, length=%d", i)
+		}
+	}
+	if err := radixtree.New().UnmarshalBinary(append(data, 0)); err == nil {
+		t.Error("no error for trailing data")
+	}
+	if _, err := radixtree.New().MarshalBinary(); err != nil {
+		t.Errorf("error for empty tree: %v", err)
+	}
+
+	unsupported := radixtree.New()
+	unsupported.Set([]byte("tea"), struct{}{})
+	if _, err := unsupported.MarshalBinary(); err == nil {
+		t.Error("no error for unsupported value type")
+	}
+}
+
+type upperStringCodec struct{}
+
+func (upperStringCodec) AppendValue(buf []byte, value interface{}) ([]byte, error) {
+	return append(buf, bytes.ToUpper([]byte(value.(string)))...), nil
+}
+
+func (upperStringCodec) DecodeValue(data []byte) (interface{}, error) {
+	return string(data), nil
+}
+
+func TestSetValueCodec(t *testing.T) {
+	tree := radixtree.New()
+	tree.SetValueCodec(upperStringCodec{})
+	tree.Set([]byte("tea"), "green")
+	tree.Set([]byte("team"), "blue")
+	data, err := tree.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := radixtree.New()
+	got.SetValueCodec(upperStringCodec{})
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	want := ".\n" +
+		"`-- \"tea\" GREEN string\n" +
+		"   `-- \"m\" BLUE string\n"
+	if got.String() != want {
+		t.Errorf("result unmatch, got=\n%s, want=\n%s", got.String(), want)
+	}
+}
+
+func TestWriteToReadFrom(t *testing.T) {
+	tree := radixtree.New()
+	tree.Set([]byte{}, "root")
+	for i := 0; i < 20000; i++ {
+		tree.Set([]byte(fmt.Sprintf("key/%05d/%d", i*7919%20000, i)), i)
+	}
+	var buf bytes.Buffer
+	n, err := tree.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("written length unmatch, got=%d, want=%d", n, buf.Len())
+	}
+	data := buf.Bytes()
+
+	got := radixtree.New()
+	n, err = got.ReadFrom(bytes.NewReader(append(data, "trailing"...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) {
+		t.Errorf("read length unmatch, got=%d, want=%d", n, len(data))
+	}
+	if got.String() != tree.String() {
+		t.Error("result unmatch")
+	}
+
+	for _, l := range []int{0, 3, 5, 8, 100, len(data) / 2, len(data) - 5, len(data) - 1} {
+		_, err := radixtree.New().ReadFrom(bytes.NewReader(data[:l]))
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("unexpected error for truncated data, length=%d, err=%v", l, err)
+		}
+	}
+
+	corrupted := append([]byte{}, data...)
+	corrupted[len(corrupted)/2] ^= 0xff
+	if _, err := radixtree.New().ReadFrom(bytes.NewReader(corrupted)); !errors.Is(err, radixtree.ErrChecksum) {
+		t.Errorf("unexpected error for corrupted data, err=%v", err)
+	}
+
+	if _, err := radixtree.New().ReadFrom(bytes.NewReader([]byte("RDXT\x01"))); !errors.Is(err, radixtree.ErrInvalidBinary) {
+		t.Errorf("unexpected error for bad magic, err=%v", err)
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	tree := radixtree.New()
+	tree.Set([]byte("water"), "3")
+	tree.Set([]byte("tea"), 1.5)
+	tree.Set([]byte("team"), nil)
+	tree.Set([]byte{}, true)
+	got, err := json.Marshal(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"":true,"tea":1.5,"team":null,"water":"3"}`
+	if string(got) != want {
+		t.Errorf("result unmatch, got=%s, want=%s", got, want)
+	}
+
+	tree2 := radixtree.New()
+	if err := json.Unmarshal(got, tree2); err != nil {
+		t.Fatal(err)
+	}
+	if tree2.String() != tree.String() {
+		t.Errorf("unmarshal result unmatch, got=\n%s, want=\n%s", tree2.String(), tree.String())
+	}
+
+	tree.Set([]byte("\xff"), 2.5)
+	tree.Set([]byte("\x00base64:"), "marker")
+	got, err = json.Marshal(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `{"":true,"\u0000base64:AGJhc2U2NDo=":"marker","tea":1.5,"team":null,"water":"3","\u0000base64:/w==":2.5}`
+	if string(got) != want {
+		t.Errorf("result unmatch for non UTF-8 key, got=%s, want=%s", got, want)
+	}
+	tree2 = radixtree.New()
+	if err := json.Unmarshal(got, tree2); err != nil {
+		t.Fatal(err)
+	}
+	if tree2.String() != tree.String() {
+		t.Errorf("unmarshal result unmatch for non UTF-8 key, got=\n%s, want=\n%s", tree2.String(), tree.String())
+	}
+	if err := json.Unmarshal([]byte(`{"\u0000base64:!":1}`), tree2); err == nil {
+		t.Error("no error for invalid base64 key")
+	}
+}
+
+func TestNestedJSON(t *testing.T) {
+	tree := radixtree.New()
+	tree.Set([]byte("è"), 1.0)
+	tree.Set([]byte("é"), nil)
+	tree.Set([]byte("tea"), "x")
+	tree.Set([]byte("team"), 2.0)
+	got, err := json.Marshal(radixtree.NestedJSON{Tree: tree})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"children":[` +
+		`{"label":"tea","value":"x","children":[{"label":"m","value":2}]},` +
+		`{"labelBase64":"ww==","children":[{"labelBase64":"qA==","value":1},{"labelBase64":"qQ==","value":null}]}` +
+		`]}`
+	if string(got) != want {
+		t.Errorf("result unmatch, got=%s, want=%s", got, want)
+	}
+
+	tree2 := radixtree.New()
+	if err := json.Unmarshal(got, &radixtree.NestedJSON{Tree: tree2}); err != nil {
+		t.Fatal(err)
+	}
+	if tree2.String() != tree.String() {
+		t.Errorf("unmarshal result unmatch, got=\n%s, want=\n%s", tree2.String(), tree.String())
+	}
+
+	invalid := `{"children":[{"label":"te","children":[{"label":"a","value":1}]}]}`
+	if err := json.Unmarshal([]byte(invalid), &radixtree.NestedJSON{Tree: tree2}); err == nil {
+		t.Error("no error for invalid structure")
+	}
+}
+
+func TestFrozenTree(t *testing.T) {
+	tree := radixtree.New()
+	tree.SetValueCodec(upperStringCodec{})
+	tree.Set([]byte("tea"), "a")
+	tree.Set([]byte("team"), "b")
+	tree.Set([]byte("tear"), "c")
+	tree.Set([]byte("teamwork"), "d")
+	tree.Set([]byte("test"), "e")
+	tree.Set([]byte("water"), "f")
+	name := filepath.Join(t.TempDir(), "tree.frozen")
+	file, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.Freeze(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := radixtree.OpenFrozen(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := f.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	getCases := []struct {
+		key    string
+		value  string
+		exists bool
+	}{
+		{key: "", exists: false},
+		{key: "te", exists: false},
+		{key: "tea", value: "A", exists: true},
+		{key: "teamwork", value: "D", exists: true},
+		{key: "teamworks", exists: false},
+		{key: "water", value: "F", exists: true},
+		{key: "wine", exists: false},
+	}
+	for i, c := range getCases {
+		value, exists := f.Get([]byte(c.key))
+		if exists != c.exists || string(value) != c.value {
+			t.Errorf("Get result unmatch, caseIndex=%d, got=%q,%v, want=%q,%v", i, value, exists, c.value, c.exists)
+		}
+	}
+
+	prefixCases := []struct {
+		key    string
+		prefix string
+		value  string
+		ok     bool
+	}{
+		{key: "te", ok: false},
+		{key: "tea", prefix: "tea", value: "A", ok: true},
+		{key: "teamster", prefix: "team", value: "B", ok: true},
+		{key: "teamworking", prefix: "teamwork", value: "D", ok: true},
+		{key: "tests", prefix: "test", value: "E", ok: true},
+		{key: "wat", ok: false},
+	}
+	for i, c := range prefixCases {
+		prefix, value, ok := f.LongestPrefix([]byte(c.key))
+		if ok != c.ok || string(prefix) != c.prefix || string(value) != c.value {
+			t.Errorf("LongestPrefix result unmatch, caseIndex=%d, got=%q,%q,%v, want=%q,%q,%v",
+				i, prefix, value, ok, c.prefix, c.value, c.ok)
+		}
+	}
+
+	walkCases := []struct {
+		prefix string
+		want   []string
+	}{
+		{prefix: "", want: []string{"tea=A", "team=B", "teamwork=D", "tear=C", "test=E", "water=F"}},
+		{prefix: "tea", want: []string{"tea=A", "team=B", "teamwork=D", "tear=C"}},
+		{prefix: "teamw", want: []string{"teamwork=D"}},
+		{prefix: "tex", want: nil},
+	}
+	for i, c := range walkCases {
+		var got []string
+		f.WalkPrefix([]byte(c.prefix), func(key, value []byte) bool {
+			got = append(got, string(key)+"="+string(value))
+			return true
+		})
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("WalkPrefix result unmatch, caseIndex=%d, got=%q, want=%q", i, got, c.want)
+		}
+	}
+
+	if _, err := radixtree.NewFrozenTree([]byte("RDXT")); !errors.Is(err, radixtree.ErrInvalidFrozen) {
+		t.Errorf("unexpected error for bad data, err=%v", err)
+	}
+}
+
+func TestCompactTree(t *testing.T) {
+	tree := radixtree.New()
+	tree.Set([]byte{}, -1)
+	rnd := rand.New(rand.NewSource(1))
+	m := map[string]interface{}{"": -1}
+	for i := 0; i < 2000; i++ {
+		b := make([]byte, 1+rnd.Intn(8))
+		for j := range b {
+			b[j] = "abc\xe3\xff"[rnd.Intn(5)]
+		}
+		tree.Set(b, i)
+		m[string(b)] = i
+	}
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	c := radixtree.NewCompactTree(tree)
+	if c.Len() != len(keys) {
+		t.Errorf("Len unmatch, got=%d, want=%d", c.Len(), len(keys))
+	}
+
+	for _, key := range append(keys, "", "d", "abcabcabcabc") {
+		wantValue, wantExists := tree.Get([]byte(key))
+		value, exists := c.Get([]byte(key))
+		if exists != wantExists || value != wantValue {
+			t.Errorf("Get unmatch, key=%q, got=%v,%v, want=%v,%v", key, value, exists, wantValue, wantExists)
+		}
+	}
+
+	for _, prefix := range []string{"", "a", "ab", "\xe3", "\xe3\xff", "cc", "d"} {
+		var got, want []string
+		c.WalkPrefix([]byte(prefix), func(key []byte, value interface{}) bool {
+			got = append(got, fmt.Sprintf("%q=%v", key, value))
+			return true
+		})
+		for _, key := range keys {
+			if strings.HasPrefix(key, prefix) {
+				want = append(want, fmt.Sprintf("%q=%v", key, m[key]))
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("WalkPrefix unmatch, prefix=%q, got=%d keys, want=%d keys", prefix, len(got), len(want))
+		}
+	}
+}
+
+func sliceIterator(keys []string) func() ([]byte, interface{}, bool) {
+	i := 0
+	return func() ([]byte, interface{}, bool) {
+		if i == len(keys) {
+			return nil, nil, false
+		}
+		i++
+		return []byte(keys[i-1]), i - 1, true
+	}
+}
+
+func TestBuildSorted(t *testing.T) {
+	testCases := [][]string{
+		nil,
+		{""},
+		{"", "tea"},
+		{"tea", "team", "teamwork", "tear", "test", "water"},
+		{"team", "tear", "test", "tester", "testing", "water"},
+		{"a", "ab", "abc", "abd", "b", "ba", "bab"},
+	}
+	for i, keys := range testCases {
+		want := radixtree.New()
+		for j, key := range keys {
+			want.Set([]byte(key), j)
+		}
+		got, err := radixtree.BuildSorted(sliceIterator(keys))
+		if err != nil {
+			t.Errorf("unexpected error, caseIndex=%d, err=%v", i, err)
+			continue
+		}
+		if got.String() != want.String() {
+			t.Errorf("result unmatch, caseIndex=%d, got=\n%s, want=\n%s", i, got.String(), want.String())
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	m := make(map[string]bool)
+	for i := 0; i < 5000; i++ {
+		b := make([]byte, rnd.Intn(8))
+		for j := range b {
+			b[j] = "abc"[rnd.Intn(3)]
+		}
+		m[string(b)] = true
+	}
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	want := radixtree.New()
+	for j, key := range keys {
+		want.Set([]byte(key), j)
+	}
+	got, err := radixtree.BuildSorted(sliceIterator(keys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Error("result unmatch for random keys")
+	}
+	for _, key := range keys {
+		if !got.Delete([]byte(key)) {
+			t.Errorf("failed to delete key %q", key)
+		}
+	}
+	if got.String() != ".\n" {
+		t.Errorf("result unmatch after deleting all keys, got=\n%s", got.String())
+	}
+
+	if _, err := radixtree.BuildSorted(sliceIterator([]string{"tea", "tea"})); !errors.Is(err, radixtree.ErrDuplicateKey) {
+		t.Errorf("unexpected error for duplicate keys, err=%v", err)
+	}
+	if _, err := radixtree.BuildSorted(sliceIterator([]string{"tea", "te"})); !errors.Is(err, radixtree.ErrUnsortedKeys) {
+		t.Errorf("unexpected error for unsorted keys, err=%v", err)
+	}
+}
+
+func benchmarkKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("tenant/%03d/object/%08d", i%1000, i)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func BenchmarkBuildSorted(b *testing.B) {
+	keys := benchmarkKeys(100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := radixtree.BuildSorted(sliceIterator(keys)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSetSorted(b *testing.B) {
+	keys := benchmarkKeys(100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t := radixtree.New()
+		for j, key := range keys {
+			t.Set([]byte(key), j)
+		}
+	}
+}
+
+func TestDurableTree(t *testing.T) {
+	dir := t.TempDir()
+	d, err := radixtree.OpenDurable(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustSet := func(d *radixtree.DurableTree, key string, value interface{}) {
+		t.Helper()
+		if err := d.Set([]byte(key), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustSet(d, "tea", 1)
+	mustSet(d, "team", 2)
+	mustSet(d, "tear", 3)
+	mustSet(d, "water", "4")
+	if deleted, err := d.Delete([]byte("tear")); err != nil || !deleted {
+		t.Fatalf("Delete failed, deleted=%v, err=%v", deleted, err)
+	}
+	if deleted, err := d.DeleteSubtree([]byte("wa")); err != nil || !deleted {
+		t.Fatalf("DeleteSubtree failed, deleted=%v, err=%v", deleted, err)
+	}
+	want := d.Tree().String()
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopen := func() *radixtree.DurableTree {
+		t.Helper()
+		d, err := radixtree.OpenDurable(dir, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	d = reopen()
+	if got := d.Tree().String(); got != want {
+		t.Errorf("result unmatch after replay, got=\n%s, want=\n%s", got, want)
+	}
+
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	mustSet(d, "test", 5)
+	want = d.Tree().String()
+	d.Close()
+
+	// Simulate a crash in the middle of appending a record.
+	logName := filepath.Join(dir, "wal")
+	f, err := os.OpenFile(logName, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte{10, 0, 0, 0, 1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	d = reopen()
+	if got := d.Tree().String(); got != want {
+		t.Errorf("result unmatch after snapshot and replay, got=\n%s, want=\n%s", got, want)
+	}
+	mustSet(d, "wine", 6)
+	want = d.Tree().String()
+	d.Close()
+
+	d = reopen()
+	if got := d.Tree().String(); got != want {
+		t.Errorf("result unmatch after torn write, got=\n%s, want=\n%s", got, want)
+	}
+	d.Close()
+
+	// Simulate a crash of the operating system which leaves a full header
+	// of garbage or zeros at the end.
+	for caseIndex, tail := range [][]byte{
+		{0xde, 0xad, 0xbe, 0xef, 1, 2, 3, 4, 5, 6, 7, 8},
+		make([]byte, 20),
+	} {
+		f, err := os.OpenFile(logName, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(tail); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		d, err := radixtree.OpenDurable(dir, nil)
+		if err != nil {
+			t.Fatalf("caseIndex=%d, unexpected error for torn header, err=%v", caseIndex, err)
+		}
+		if got := d.Tree().String(); got != want {
+			t.Errorf("caseIndex=%d, result unmatch after torn header, got=\n%s, want=\n%s", caseIndex, got, want)
+		}
+		d.Close()
+	}
+
+	// Corrupt a record which is not the last one.
+	data, err := os.ReadFile(logName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[13] ^= 0xff
+	if err := os.WriteFile(logName, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := radixtree.OpenDurable(dir, nil); !errors.Is(err, radixtree.ErrChecksum) {
+		t.Errorf("unexpected error for corrupted log, err=%v", err)
+	}
+	data[13] ^= 0xff
+
+	// Corrupt the length of a record which is not the last one so that
+	// it points past the end of the log.
+	dir = t.TempDir()
+	d, err = radixtree.OpenDurable(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		mustSet(d, key, 1)
+	}
+	d.Close()
+	logName = filepath.Join(dir, "wal")
+	data, err = os.ReadFile(logName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := 12 + int(data[0])
+	data[second+2] = 0x01
+	if err := os.WriteFile(logName, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := radixtree.OpenDurable(dir, nil); !errors.Is(err, radixtree.ErrChecksum) {
+		t.Errorf("unexpected error for corrupted record length, err=%v", err)
+	}
+	if fi, err := os.Stat(logName); err != nil || fi.Size() != int64(len(data)) {
+		t.Errorf("log is modified after corrupted record length, err=%v", err)
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	tree := radixtree.New()
+	tree.Set([]byte("tea"), 1)
+	tree.Set([]byte("team"), 2)
+	tree.Set([]byte("tear"), 3)
+	tree.Set([]byte("wa\"ter"), "4")
+
+	testCases := []struct {
+		opts *radixtree.DOTOptions
+		want string
+	}{
+		{
+			opts: nil,
+			want: "digraph radixtree {\n" +
+				"\tnode [shape=box];\n" +
+				"\tn0 [label=\".\"];\n" +
+				"\tn1 [label=\"\\\"tea\\\" 1 int\"];\n" +
+				"\tn2 [label=\"\\\"m\\\" 2 int\"];\n" +
+				"\tn1 -> n2;\n" +
+				"\tn3 [label=\"\\\"r\\\" 3 int\"];\n" +
+				"\tn1 -> n3;\n" +
+				"\tn0 -> n1;\n" +
+				"\tn4 [label=\"\\\"wa\\\\\\\"ter\\\" 4 string\"];\n" +
+				"\tn0 -> n4;\n" +
+				"}\n",
+		},
+		{
+			opts: &radixtree.DOTOptions{MaxDepth: 1},
+			want: "digraph radixtree {\n" +
+				"\tnode [shape=box];\n" +
+				"\tn0 [label=\".\"];\n" +
+				"\tn1 [label=\"\\\"tea\\\" 1 int\", style=dashed];\n" +
+				"\tn0 -> n1;\n" +
+				"\tn2 [label=\"\\\"wa\\\\\\\"ter\\\" 4 string\"];\n" +
+				"\tn0 -> n2;\n" +
+				"}\n",
+		},
+		{
+			opts: &radixtree.DOTOptions{Prefix: []byte("te")},
+			want: "digraph radixtree {\n" +
+				"\tnode [shape=box];\n" +
+				"\tn0 [label=\"\\\"tea\\\" 1 int\"];\n" +
+				"\tn1 [label=\"\\\"m\\\" 2 int\"];\n" +
+				"\tn0 -> n1;\n" +
+				"\tn2 [label=\"\\\"r\\\" 3 int\"];\n" +
+				"\tn0 -> n2;\n" +
+				"}\n",
+		},
+		{
+			opts: &radixtree.DOTOptions{Prefix: []byte("x")},
+			want: "digraph radixtree {\n" +
+				"\tnode [shape=box];\n" +
+				"}\n",
+		},
+	}
+	for i, c := range testCases {
+		var buf bytes.Buffer
+		if err := tree.WriteDOT(&buf, c.opts); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != c.want {
+			t.Errorf("result unmatch, caseIndex=%d, got=\n%s, want=\n%s", i, got, c.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tree := radixtree.New()
+	tree.Set([]byte{}, 0)
+	tree.Set([]byte("tea"), 1)
+	tree.Set([]byte("team"), 2)
+	tree.Set([]byte("tear"), 3)
+	tree.Set([]byte("test"), 4)
+	tree.Set([]byte("water"), 5)
+
+	testCases := []struct {
+		opts radixtree.FormatOptions
+		want string
+	}{
+		{
+			opts: radixtree.FormatOptions{},
+			want: tree.String(),
+		},
+		{
+			opts: radixtree.FormatOptions{HideTypes: true, Unicode: true},
+			want: ". 0\n" +
+				"├── \"te\"\n" +
+				"│  ├── \"a\" 1\n" +
+				"│  │  ├── \"m\" 2\n" +
+				"│  │  └── \"r\" 3\n" +
+				"│  └── \"st\" 4\n" +
+				"└── \"water\" 5\n",
+		},
+		{
+			opts: radixtree.FormatOptions{
+				FormatValue: func(v interface{}) string { return fmt.Sprintf("<%d>", v) },
+				MaxDepth:    2,
+			},
+			want: ". <0>\n" +
+				"|-- \"te\"\n" +
+				"|  |-- \"a\" <1>\n" +
+				"|  |  `-- ... (2 more)\n" +
+				"|  `-- \"st\" <4>\n" +
+				"`-- \"water\" <5>\n",
+		},
+		{
+			opts: radixtree.FormatOptions{MaxChildren: 1, HideTypes: true},
+			want: ". 0\n" +
+				"|-- \"te\"\n" +
+				"|  |-- \"a\" 1\n" +
+				"|  |  |-- \"m\" 2\n" +
+				"|  |  `-- ... (1 more)\n" +
+				"|  `-- ... (1 more)\n" +
+				"`-- ... (1 more)\n",
+		},
+		{
+			opts: radixtree.FormatOptions{Prefix: []byte("tea"), HideTypes: true},
+			want: "\"tea\" 1\n" +
+				"|-- \"m\" 2\n" +
+				"`-- \"r\" 3\n",
+		},
+		{
+			opts: radixtree.FormatOptions{Prefix: []byte("x")},
+			want: "",
+		},
+	}
+	for i, c := range testCases {
+		var buf bytes.Buffer
+		if err := tree.Format(&buf, c.opts); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != c.want {
+			t.Errorf("result unmatch, caseIndex=%d, got=\n%s, want=\n%s", i, got, c.want)
+		}
+	}
+}
+
+func TestParseASCII(t *testing.T) {
+	intParser := func(s string) (interface{}, error) {
+		return strconv.Atoi(strings.TrimSuffix(s, " int"))
+	}
+
+	tree := radixtree.New()
+	tree.Set([]byte{}, 0)
+	tree.Set([]byte("tea"), 1)
+	tree.Set([]byte("team"), 2)
+	tree.Set([]byte("teamwork"), 3)
+	tree.Set([]byte("tear"), 4)
+	tree.Set([]byte("test"), 5)
+	tree.Set([]byte("wa\"ter\xff"), 6)
+	s := tree.String()
+	got, err := radixtree.ParseASCII(s, intParser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != s {
+		t.Errorf("result unmatch, got=\n%s, want=\n%s", got.String(), s)
+	}
+
+	got, err = radixtree.ParseASCII(".\n`-- \"tea\" green tea\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := got.Get([]byte("tea")); v != "green tea" {
+		t.Errorf("value unmatch, got=%v, want=%v", v, "green tea")
+	}
+
+	errorCases := []struct {
+		input        string
+		line, column int
+	}{
+		{input: "", line: 1, column: 1},
+		{input: "x\n", line: 1, column: 1},
+		{input: ".\n`-- tea\n", line: 2, column: 5},
+		{input: ".\n`-- \"tea\" x int\n", line: 2, column: 11},
+		{input: ".\n|-- \"tea\" 1 int\n|  `-- \"m\" 2 int\n    `-- \"x\" 3 int\n", line: 4, column: 1},
+		{input: ".\n`-- \"tea\" 1 int\n`-- \"water\" 2 int\n", line: 3, column: 1},
+		{input: ".\n|-- \"water\" 1 int\n`-- \"tea\" 2 int\n", line: 1, column: 1},
+		{input: ".\n`-- \"te\"\n   `-- \"a\" 1 int\n", line: 2, column: 1},
+	}
+	for i, c := range errorCases {
+		_, err := radixtree.ParseASCII(c.input, intParser)
+		var perr *radixtree.ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("unexpected error, caseIndex=%d, err=%v", i, err)
+			continue
+		}
+		if perr.Line != c.line || perr.Column != c.column {
+			t.Errorf("position unmatch, caseIndex=%d, got=%d:%d, want=%d:%d, err=%v",
+				i, perr.Line, perr.Column, c.line, c.column, err)
+		}
+	}
+}
+
+// randomTree returns a tree with random keys made of a few bytes and
+// the sorted keys. Values are the keys as strings.
+func randomTree(seed int64, n int) (*radixtree.Tree, []string) {
+	rnd := rand.New(rand.NewSource(seed))
+	tree := radixtree.New()
+	m := make(map[string]bool)
+	for i := 0; i < n; i++ {
+		b := make([]byte, rnd.Intn(6))
+		for j := range b {
+			b[j] = "abc"[rnd.Intn(3)]
+		}
+		tree.Set(b, string(b))
+		m[string(b)] = true
+	}
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return tree, keys
+}
+
+func TestIterator(t *testing.T) {
+	tree, keys := randomTree(1, 200)
+
+	var got []string
+	it := tree.Iterator()
+	for it.Next() {
+		if it.Value() != string(it.Key()) {
+			t.Errorf("value unmatch, key=%q, value=%v", it.Key(), it.Value())
+		}
+		got = append(got, string(it.Key()))
+	}
+	if !reflect.DeepEqual(got, keys) {
+		t.Errorf("Next result unmatch, got=%q, want=%q", got, keys)
+	}
+	if it.Next() || it.Valid() {
+		t.Error("iterator is valid after the end")
+	}
+
+	got = nil
+	for it = tree.Iterator(); it.Prev(); {
+		got = append(got, string(it.Key()))
+	}
+	for i, j := 0, len(got)-1; i < j; i, j = i+1, j-1 {
+		got[i], got[j] = got[j], got[i]
+	}
+	if !reflect.DeepEqual(got, keys) {
+		t.Errorf("Prev result unmatch, got=%q, want=%q", got, keys)
+	}
+
+	for _, target := range []string{"", "a", "ab", "abb", "abca", "b", "bbbbbb", "c", "cccccc", "d"} {
+		i := sort.SearchStrings(keys, target)
+		it := tree.Iterator()
+		ok := it.Seek([]byte(target))
+		if ok != (i < len(keys)) {
+			t.Errorf("Seek result unmatch, target=%q, got=%v", target, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if string(it.Key()) != keys[i] {
+			t.Errorf("Seek key unmatch, target=%q, got=%q, want=%q", target, it.Key(), keys[i])
+		}
+		if it.Next() != (i+1 < len(keys)) || (i+1 < len(keys) && string(it.Key()) != keys[i+1]) {
+			t.Errorf("Next after Seek unmatch, target=%q", target)
+		}
+		it.Seek([]byte(target))
+		if it.Prev() != (i > 0) || (i > 0 && string(it.Key()) != keys[i-1]) {
+			t.Errorf("Prev after Seek unmatch, target=%q", target)
+		}
+	}
+
+	for _, prefix := range []string{"", "a", "ab", "abc", "ca", "d"} {
+		var want []string
+		for _, key := range keys {
+			if strings.HasPrefix(key, prefix) {
+				want = append(want, key)
+			}
+		}
+		var got []string
+		it := tree.Iterator()
+		for ok := it.SeekPrefix([]byte(prefix)); ok; ok = it.Next() {
+			got = append(got, string(it.Key()))
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("SeekPrefix result unmatch, prefix=%q, got=%q, want=%q", prefix, got, want)
+		}
+		if len(want) > 0 {
+			if !it.Last() || string(it.Key()) != want[len(want)-1] {
+				t.Errorf("Last unmatch, prefix=%q", prefix)
+			}
+			if it.Seek([]byte("")) != true || string(it.Key()) != want[0] {
+				t.Errorf("Seek before prefix unmatch, prefix=%q", prefix)
+			}
+			if it.Seek([]byte("d")) {
+				t.Errorf("Seek after prefix unmatch, prefix=%q", prefix)
+			}
+		}
+	}
+
+	if radixtree.New().Iterator().Next() {
+		t.Error("Next returns true for empty tree")
+	}
+	tree = radixtree.New()
+	for _, key := range []string{"a", "ab", "ac"} {
+		tree.Set([]byte(key), key)
+	}
+	it = tree.Iterator()
+	it.Next()
+	tree.Delete([]byte("a"))
+	if string(it.Key()) != "a" || it.Value() != "a" {
+		t.Errorf("current key and value unmatch after Delete, got=%q,%v", it.Key(), it.Value())
+	}
+	tree.Set([]byte("ab"), "new")
+	if !it.Next() || string(it.Key()) != "ab" || it.Value() != "new" {
+		t.Errorf("Next after Delete unmatch, got=%q,%v", it.Key(), it.Value())
+	}
+	tree.Set([]byte("ab"), "newer")
+	if it.Value() != "new" {
+		t.Errorf("current value unmatch after Set, got=%v", it.Value())
+	}
+}
+
+func TestWalk(t *testing.T) {
+	tree, keys := randomTree(2, 200)
+	reversed := make([]string, len(keys))
+	for i, key := range keys {
+		reversed[len(keys)-1-i] = key
+	}
+
+	collect := func(walk func(fn func(key []byte, value interface{}) bool), limit int) []string {
+		var got []string
+		walk(func(key []byte, value interface{}) bool {
+			if value != string(key) {
+				t.Errorf("value unmatch, key=%q, value=%v", key, value)
+			}
+			got = append(got, string(key))
+			return len(got) != limit
+		})
+		return got
+	}
+	if got := collect(tree.Walk, -1); !reflect.DeepEqual(got, keys) {
+		t.Errorf("Walk result unmatch, got=%q, want=%q", got, keys)
+	}
+	if got := collect(tree.WalkReverse, -1); !reflect.DeepEqual(got, reversed) {
+		t.Errorf("WalkReverse result unmatch, got=%q, want=%q", got, reversed)
+	}
+	if got := collect(tree.WalkReverse, 3); !reflect.DeepEqual(got, reversed[:3]) {
+		t.Errorf("WalkReverse result unmatch after stop, got=%q, want=%q", got, reversed[:3])
+	}
+
+	for _, prefix := range []string{"", "a", "ab", "abc", "ca", "d"} {
+		var want, wantReverse []string
+		for i := range keys {
+			if strings.HasPrefix(keys[i], prefix) {
+				want = append(want, keys[i])
+			}
+			if strings.HasPrefix(reversed[i], prefix) {
+				wantReverse = append(wantReverse, reversed[i])
+			}
+		}
+		walk := func(fn func(key []byte, value interface{}) bool) {
+			tree.WalkPrefix([]byte(prefix), fn)
+		}
+		if got := collect(walk, -1); !reflect.DeepEqual(got, want) {
+			t.Errorf("WalkPrefix result unmatch, prefix=%q, got=%q, want=%q", prefix, got, want)
+		}
+		walk = func(fn func(key []byte, value interface{}) bool) {
+			tree.WalkPrefixReverse([]byte(prefix), fn)
+		}
+		if got := collect(walk, -1); !reflect.DeepEqual(got, wantReverse) {
+			t.Errorf("WalkPrefixReverse result unmatch, prefix=%q, got=%q, want=%q", prefix, got, wantReverse)
+		}
+	}
+}
+
+func TestWalkRange(t *testing.T) {
+	tree, keys := randomTree(3, 200)
+	bounds := []string{"", "a", "aa", "ab", "abc", "abcab", "b", "ba", "bcc", "c", "cccccc", "d"}
+	for caseIndex := 0; caseIndex < len(bounds)*len(bounds)*8; caseIndex++ {
+		start := []byte(bounds[caseIndex/8/len(bounds)])
+		end := []byte(bounds[caseIndex/8%len(bounds)])
+		opts := radixtree.RangeOptions{
+			StartExclusive: caseIndex&1 != 0,
+			EndInclusive:   caseIndex&2 != 0,
+			Reverse:        caseIndex&4 != 0,
+		}
+		var want []string
+		for _, key := range keys {
+			if c := strings.Compare(key, string(start)); c < 0 || (c == 0 && opts.StartExclusive) {
+				continue
+			}
+			if c := strings.Compare(key, string(end)); c > 0 || (c == 0 && !opts.EndInclusive) {
+				continue
+			}
+			want = append(want, key)
+		}
+		if opts.Reverse {
+			for i, j := 0, len(want)-1; i < j; i, j = i+1, j-1 {
+				want[i], want[j] = want[j], want[i]
+			}
+		}
+		var got []string
+		tree.WalkRangeWithOptions(start, end, opts, func(key []byte, value interface{}) bool {
+			got = append(got, string(key))
+			return true
+		})
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("caseIndex=%d, start=%q, end=%q, opts=%+v, got=%q, want=%q", caseIndex, start, end, opts, got, want)
+		}
+	}
+
+	var got []string
+	tree.WalkRange([]byte("b"), nil, func(key []byte, value interface{}) bool {
+		got = append(got, string(key))
+		return len(got) < 3
+	})
+	i := sort.SearchStrings(keys, "b")
+	if want := keys[i : i+3]; !reflect.DeepEqual(got, want) {
+		t.Errorf("WalkRange with nil end unmatch, got=%q, want=%q", got, want)
+	}
+
+	got = nil
+	tree.WalkRangeReverse(nil, []byte("b"), func(key []byte, value interface{}) bool {
+		got = append(got, string(key))
+		return true
+	})
+	if len(got) != i || (i > 0 && (got[0] != keys[i-1] || got[i-1] != keys[0])) {
+		t.Errorf("WalkRangeReverse with nil start unmatch, got=%q", got)
+	}
+}
+
+func TestDeleteRange(t *testing.T) {
+	bounds := []string{"", "a", "aa", "ab", "abc", "abcab", "b", "ba", "bcc", "c", "cccccc", "d"}
+	for caseIndex := 0; caseIndex < len(bounds)*len(bounds); caseIndex++ {
+		start := []byte(bounds[caseIndex/len(bounds)])
+		end := []byte(bounds[caseIndex%len(bounds)])
+		tree, keys := randomTree(int64(caseIndex), 100)
+		want := radixtree.New()
+		var wantRemoved []string
+		for _, key := range keys {
+			if key >= string(start) && key < string(end) {
+				wantRemoved = append(wantRemoved, key)
+			} else {
+				want.Set([]byte(key), key)
+			}
+		}
+		var gotRemoved []string
+		tree.Watch(nil, func(op radixtree.Op, key []byte) {
+			gotRemoved = append(gotRemoved, string(key))
+		})
+		if got := tree.DeleteRange(start, end); got != len(wantRemoved) {
+			t.Errorf("removed count unmatch, caseIndex=%d, got=%d, want=%d", caseIndex, got, len(wantRemoved))
+		}
+		if got, want := tree.String(), want.String(); got != want {
+			t.Errorf("tree unmatch, caseIndex=%d, start=%q, end=%q,\ngot=\n%s\nwant=\n%s", caseIndex, start, end, got, want)
+		}
+		sort.Strings(gotRemoved)
+		if !reflect.DeepEqual(gotRemoved, wantRemoved) {
+			t.Errorf("notified keys unmatch, caseIndex=%d, got=%q, want=%q", caseIndex, gotRemoved, wantRemoved)
+		}
+	}
+
+	tree, _ := randomTree(1, 100)
+	o := &recordingObserver{}
+	tree.SetObserver(o)
+	if got := tree.DeleteRange([]byte("b"), nil); got == 0 || len(o.events) != got {
+		t.Errorf("observer events unmatch, removed=%d, events=%q", got, o.events)
+	}
+	tree.DeleteRange(nil, []byte("b"))
+	if got := tree.String(); got != ".\n" {
+		t.Errorf("tree is not empty after deleting all ranges, got=%q", got)
+	}
+}
+
+func TestNearestKeys(t *testing.T) {
+	tree, keys := randomTree(4, 100)
+	targets := []string{"", "a", "aa", "aab", "ab", "abc", "abcab", "abcabc", "b", "ba", "bcc", "c", "cccccc", "d"}
+	targets = append(targets, keys...)
+	for caseIndex, target := range targets {
+		i := sort.SearchStrings(keys, target)
+		found := i < len(keys) && keys[i] == target
+		check := func(name string, key []byte, value interface{}, ok bool, j int) {
+			if j < 0 || j >= len(keys) {
+				if ok {
+					t.Errorf("%s result unmatch, caseIndex=%d, target=%q, got=%q, want none", name, caseIndex, target, key)
+				}
+				return
+			}
+			if !ok || string(key) != keys[j] || value != keys[j] {
+				t.Errorf("%s result unmatch, caseIndex=%d, target=%q, got=%q,%v,%v, want=%q", name, caseIndex, target, key, value, ok, keys[j])
+			}
+		}
+		ceiling, higher, floor, lower := i, i, i-1, i-1
+		if found {
+			higher++
+			floor++
+		}
+		key, value, ok := tree.Ceiling([]byte(target))
+		check("Ceiling", key, value, ok, ceiling)
+		key, value, ok = tree.Higher([]byte(target))
+		check("Higher", key, value, ok, higher)
+		key, value, ok = tree.Floor([]byte(target))
+		check("Floor", key, value, ok, floor)
+		key, value, ok = tree.Lower([]byte(target))
+		check("Lower", key, value, ok, lower)
+	}
+
+	if key, _, ok := tree.Min(); !ok || string(key) != keys[0] {
+		t.Errorf("Min result unmatch, got=%q, want=%q", key, keys[0])
+	}
+	if key, _, ok := tree.Max(); !ok || string(key) != keys[len(keys)-1] {
+		t.Errorf("Max result unmatch, got=%q, want=%q", key, keys[len(keys)-1])
+	}
+	empty := radixtree.New()
+	if _, _, ok := empty.Min(); ok {
+		t.Error("Min returns ok for empty tree")
+	}
+	if _, _, ok := empty.Floor([]byte("a")); ok {
+		t.Error("Floor returns ok for empty tree")
+	}
+}
+
+func TestSelectRank(t *testing.T) {
+	tree, keys := randomTree(5, 200)
+	for i := 0; i < 3; i++ {
+		tree.DeleteSubtree([]byte(keys[i*20]))
+		tree.Delete([]byte(keys[i*20+10]))
+	}
+	tree.DeleteRange([]byte("bb"), []byte("bc"))
+	keys = keys[:0]
+	tree.Walk(func(key []byte, value interface{}) bool {
+		keys = append(keys, string(key))
+		return true
+	})
+
+	for i, key := range keys {
+		got, value, ok := tree.Select(i)
+		if !ok || string(got) != key || value != key {
+			t.Errorf("Select result unmatch, i=%d, got=%q,%v,%v, want=%q", i, got, value, ok, key)
+		}
+		if got := tree.Rank([]byte(key)); got != i {
+			t.Errorf("Rank result unmatch, key=%q, got=%d, want=%d", key, got, i)
+		}
+	}
+	for _, i := range []int{-1, len(keys)} {
+		if _, _, ok := tree.Select(i); ok {
+			t.Errorf("Select returns ok for out of range index %d", i)
+		}
+	}
+	for _, key := range []string{"", "a", "aab", "abcab", "b", "bba", "cccccc", "d"} {
+		want := sort.SearchStrings(keys, key)
+		if got := tree.Rank([]byte(key)); got != want {
+			t.Errorf("Rank result unmatch, key=%q, got=%d, want=%d", key, got, want)
+		}
+	}
+}
+
+func TestCountPrefix(t *testing.T) {
+	tree, keys := randomTree(6, 200)
+	tree.DeleteSubtree([]byte("ab"))
+	tree.Delete([]byte(keys[len(keys)/2]))
+	tree.Set([]byte("cccccc"), "cccccc")
+	tree.DeleteRange([]byte("ba"), []byte("bab"))
+	keys = keys[:0]
+	tree.Walk(func(key []byte, value interface{}) bool {
+		keys = append(keys, string(key))
+		return true
+	})
+
+	for caseIndex, prefix := range []string{"", "a", "ab", "abc", "b", "ba", "bab", "c", "ccc", "cccccc", "ccccccc", "d"} {
+		want := 0
+		for _, key := range keys {
+			if strings.HasPrefix(key, prefix) {
+				want++
+			}
+		}
+		if got := tree.CountPrefix([]byte(prefix)); got != want {
+			t.Errorf("caseIndex=%d, prefix=%q, got=%d, want=%d", caseIndex, prefix, got, want)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	tree := radixtree.New()
+	for _, key := range []string{"a", "a/", "a/b", "a/c/d", "a/c/e", "a/d", "a/e/f", "ab", "b/c"} {
+		tree.Set([]byte(key), key)
+	}
+	toStrings := func(b [][]byte) []string {
+		var s []string
+		for _, v := range b {
+			s = append(s, string(v))
+		}
+		return s
+	}
+	testCases := []struct {
+		prefix, startAfter, delimiter string
+		limit                         int
+		keys, commonPrefixes          []string
+		next                          string
+	}{
+		{prefix: "", keys: []string{"a", "a/", "a/b", "a/c/d", "a/c/e", "a/d", "a/e/f", "ab", "b/c"}},
+		{prefix: "a/", limit: 3, keys: []string{"a/", "a/b", "a/c/d"}, next: "a/c/d"},
+		{prefix: "a/", startAfter: "a/c/d", limit: 3, keys: []string{"a/c/e", "a/d", "a/e/f"}},
+		{prefix: "a/", startAfter: "a/e/f", limit: 3},
+		{prefix: "", delimiter: "/", keys: []string{"a", "ab"}, commonPrefixes: []string{"a/", "b/"}},
+		{prefix: "a/", delimiter: "/", keys: []string{"a/", "a/b", "a/d"}, commonPrefixes: []string{"a/c/", "a/e/"}},
+		{prefix: "a/", delimiter: "/", limit: 3, keys: []string{"a/", "a/b"}, commonPrefixes: []string{"a/c/"}, next: "a/c/"},
+		{prefix: "a/", startAfter: "a/c/", delimiter: "/", limit: 3, keys: []string{"a/d"}, commonPrefixes: []string{"a/e/"}},
+		{prefix: "a/", startAfter: "a/c/d", delimiter: "/", keys: []string{"a/d"}, commonPrefixes: []string{"a/e/"}},
+		{prefix: "a/c", delimiter: "/", commonPrefixes: []string{"a/c/"}},
+		{prefix: "x", delimiter: "/"},
+	}
+	for caseIndex, tc := range testCases {
+		var startAfter []byte
+		if tc.startAfter != "" {
+			startAfter = []byte(tc.startAfter)
+		}
+		keys, values, commonPrefixes, next := tree.ListWithDelimiter([]byte(tc.prefix), startAfter, []byte(tc.delimiter), tc.limit)
+		if got := toStrings(keys); !reflect.DeepEqual(got, tc.keys) {
+			t.Errorf("keys unmatch, caseIndex=%d, got=%q, want=%q", caseIndex, got, tc.keys)
+		}
+		for i, v := range values {
+			if v != string(keys[i]) {
+				t.Errorf("value unmatch, caseIndex=%d, key=%q, value=%v", caseIndex, keys[i], v)
+			}
+		}
+		if got := toStrings(commonPrefixes); !reflect.DeepEqual(got, tc.commonPrefixes) {
+			t.Errorf("commonPrefixes unmatch, caseIndex=%d, got=%q, want=%q", caseIndex, got, tc.commonPrefixes)
+		}
+		if string(next) != tc.next || (tc.next == "" && next != nil) {
+			t.Errorf("next unmatch, caseIndex=%d, got=%q, want=%q", caseIndex, next, tc.next)
+		}
+	}
+
+	big, want := randomTree(7, 200)
+	var got []string
+	var next []byte
+	for {
+		var keys [][]byte
+		keys, _, next = big.List(nil, next, 7)
+		got = append(got, toStrings(keys)...)
+		if next == nil {
+			break
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("paginated keys unmatch, got=%q, want=%q", got, want)
+	}
+}
+
+func TestChildren(t *testing.T) {
+	tree := radixtree.New()
+	for _, key := range []string{"a", "a/", "a/b", "a/b/c", "a/b0", "a/c/d", "a/c/e", "a//f", "a/d", "ab", "b/c"} {
+		tree.Set([]byte(key), key)
+	}
+	testCases := []struct {
+		prefix string
+		want   []string
+	}{
+		{prefix: "", want: []string{"a key dir", "ab key", "b dir"}},
+		{prefix: "a/", want: []string{" dir", "b key dir", "b0 key", "c dir", "d key"}},
+		{prefix: "a/b", want: []string{" dir", "0 key"}},
+		{prefix: "a/b/", want: []string{"c key"}},
+		{prefix: "a/c", want: []string{" dir"}},
+		{prefix: "b", want: []string{" dir"}},
+		{prefix: "x"},
+	}
+	for caseIndex, tc := range testCases {
+		var got []string
+		for _, s := range tree.Children([]byte(tc.prefix), '/') {
+			v := string(s.Segment)
+			if s.IsKey {
+				v += " key"
+			}
+			if s.HasDescendants {
+				v += " dir"
+			}
+			got = append(got, v)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("caseIndex=%d, prefix=%q, got=%q, want=%q", caseIndex, tc.prefix, got, tc.want)
+		}
+	}
+}
+
+func TestTopK(t *testing.T) {
+	tree, _ := randomTree(8, 200)
+	byLength := radixtree.NewScorer(func(key []byte, value interface{}) float64 {
+		return float64(strings.Count(string(key), "a")*3 - len(key))
+	})
+	byC := radixtree.NewScorer(func(key []byte, value interface{}) float64 {
+		if strings.HasSuffix(string(key), "b") {
+			return math.NaN()
+		}
+		return float64(strings.Count(string(key), "c"))
+	})
+	score := func(scorer *radixtree.Scorer, key string) float64 {
+		if scorer == byLength {
+			return float64(strings.Count(key, "a")*3 - len(key))
+		}
+		if strings.HasSuffix(key, "b") {
+			return math.NaN()
+		}
+		return float64(strings.Count(key, "c"))
+	}
+
+	for caseIndex := 0; caseIndex < 40; caseIndex++ {
+		scorer := byLength
+		if caseIndex%3 == 2 {
+			scorer = byC
+		}
+		prefix := []string{"", "a", "b", "ca", "cab"}[caseIndex%5]
+		k := caseIndex%7 + 1
+		switch caseIndex % 4 {
+		case 1:
+			tree.Set([]byte(fmt.Sprintf("aaaa%d", caseIndex)), "x")
+		case 2:
+			tree.DeleteSubtree([]byte("aaaa1"))
+		case 3:
+			tree.Delete([]byte("aaa"))
+		}
+
+		type entry struct {
+			key   string
+			score float64
+		}
+		var want []entry
+		tree.WalkPrefix([]byte(prefix), func(key []byte, value interface{}) bool {
+			if s := score(scorer, string(key)); !math.IsNaN(s) {
+				want = append(want, entry{string(key), s})
+			}
+			return true
+		})
+		sort.SliceStable(want, func(i, j int) bool { return want[i].score > want[j].score })
+		if len(want) > k {
+			want = want[:k]
+		}
+		var got []entry
+		for _, c := range tree.TopK([]byte(prefix), k, scorer) {
+			got = append(got, entry{string(c.Key), c.Score})
+		}
+		if len(got) != len(want) || (len(got) > 0 && !reflect.DeepEqual(got, want)) {
+			t.Errorf("caseIndex=%d, prefix=%q, k=%d, got=%v, want=%v", caseIndex, prefix, k, got, want)
+		}
+	}
+}
+
+func TestFuzzySearch(t *testing.T) {
+	levenshtein := func(a, b string) int {
+		row := make([]int, len(b)+1)
+		for j := range row {
+			row[j] = j
+		}
+		for i := 1; i <= len(a); i++ {
+			prev := row[0]
+			row[0] = i
+			for j := 1; j <= len(b); j++ {
+				d := prev
+				if a[i-1] != b[j-1] {
+					d++
+				}
+				if row[j]+1 < d {
+					d = row[j] + 1
+				}
+				if row[j-1]+1 < d {
+					d = row[j-1] + 1
+				}
+				prev, row[j] = row[j], d
+			}
+		}
+		return row[len(b)]
+	}
+
+	tree, keys := randomTree(9, 200)
+	targets := []string{"", "a", "abc", "bca", "ccccc", "abcabc", "xyz"}
+	for caseIndex := 0; caseIndex < len(targets)*4; caseIndex++ {
+		target := targets[caseIndex/4]
+		maxDistance := caseIndex % 4
+		var want []string
+		for _, key := range keys {
+			if d := levenshtein(key, target); d <= maxDistance {
+				want = append(want, fmt.Sprintf("%s:%d", key, d))
+			}
+		}
+		var got []string
+		for _, m := range tree.FuzzySearch([]byte(target), maxDistance) {
+			if m.Value != string(m.Key) {
+				t.Errorf("value unmatch, caseIndex=%d, key=%q, value=%v", caseIndex, m.Key, m.Value)
+			}
+			got = append(got, fmt.Sprintf("%s:%d", m.Key, m.Distance))
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("caseIndex=%d, target=%q, maxDistance=%d, got=%q, want=%q", caseIndex, target, maxDistance, got, want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tree := radixtree.New()
+	for _, key := range []string{"svc", "svc/a/db/primary", "svc/a/db/secondary", "svc/b/db/primary", "svc/b/c/db/primary", "svc/b/db/xrimary", "svc/*/db", "web/db/primary"} {
+		tree.Set([]byte(key), key)
+	}
+	sep := &radixtree.MatchOptions{StopAtSeparator: true, Separator: '/'}
+	testCases := []struct {
+		pattern string
+		opts    *radixtree.MatchOptions
+		want    []string
+	}{
+		{pattern: "svc/*/db/?rimary", opts: sep, want: []string{"svc/a/db/primary", "svc/b/db/primary", "svc/b/db/xrimary"}},
+		{pattern: "svc/*/db/?rimary", want: []string{"svc/a/db/primary", "svc/b/c/db/primary", "svc/b/db/primary", "svc/b/db/xrimary"}},
+		{pattern: "svc/*/db/[p-q]rimary", opts: sep, want: []string{"svc/a/db/primary", "svc/b/db/primary"}},
+		{pattern: "svc/*/db/[!p]rimary", opts: sep, want: []string{"svc/b/db/xrimary"}},
+		{pattern: "svc/[^ab]/*", opts: sep, want: []string{"svc/*/db"}},
+		{pattern: `svc/\*/db`, want: []string{"svc/*/db"}},
+		{pattern: `svc/[\*]/db`, want: []string{"svc/*/db"}},
+		{pattern: "*", opts: sep, want: []string{"svc"}},
+		{pattern: "*", want: []string{"svc", "svc/*/db", "svc/a/db/primary", "svc/a/db/secondary", "svc/b/c/db/primary", "svc/b/db/primary", "svc/b/db/xrimary", "web/db/primary"}},
+		{pattern: "*primary", opts: sep},
+		{pattern: "*/db/*", opts: sep, want: []string{"web/db/primary"}},
+		{pattern: "w**y", want: []string{"web/db/primary"}},
+		{pattern: "svc?"},
+	}
+	for caseIndex, tc := range testCases {
+		var got []string
+		err := tree.Match([]byte(tc.pattern), tc.opts, func(key []byte, value interface{}) bool {
+			got = append(got, string(key))
+			return true
+		})
+		if err != nil {
+			t.Errorf("unexpected error, caseIndex=%d, err=%v", caseIndex, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("caseIndex=%d, pattern=%q, got=%q, want=%q", caseIndex, tc.pattern, got, tc.want)
+		}
+	}
+
+	for caseIndex, pattern := range []string{"[", "[]", "[a", "[z-a]", `a\`, `[\`} {
+		err := tree.Match([]byte(pattern), nil, func(key []byte, value interface{}) bool {
+			return true
+		})
+		if !errors.Is(err, radixtree.ErrBadPattern) {
+			t.Errorf("caseIndex=%d, pattern=%q, got=%v, want ErrBadPattern", caseIndex, pattern, err)
+		}
+	}
+
+	var got []string
+	tree.Match([]byte("svc*"), nil, func(key []byte, value interface{}) bool {
+		got = append(got, string(key))
+		return len(got) < 2
+	})
+	if want := []string{"svc", "svc/*/db"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("result unmatch after stop, got=%q, want=%q", got, want)
+	}
+}
@@ -0,0 +1,90 @@
+package radixtree
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnsortedKeys is returned from BuildSorted when a key is less
+	// than the previous one.
+	ErrUnsortedKeys = errors.New("radixtree: keys are not sorted")
+
+	// ErrDuplicateKey is returned from BuildSorted when a key is equal
+	// to the previous one.
+	ErrDuplicateKey = errors.New("radixtree: duplicate key")
+)
+
+// BuildSorted builds a radix tree from keys and values returned by next,
+// which must return keys in ascending lexicographic order and return
+// false for ok after the last key. You are free to modify the backing
+// store of the key after next returns it again.
+//
+// The tree is built in one pass, keeping only the path to the last key,
+// so it avoids binary searches and slice insertions which Set does.
+// BuildSorted returns an error wrapping ErrUnsortedKeys or
+// ErrDuplicateKey if keys are not in strictly ascending order.
+func BuildSorted(next func() (key []byte, value interface{}, ok bool)) (*Tree, error) {
+	t := New()
+
+	type pathEntry struct {
+		n     *node
+		depth int // the length of the key of n
+	}
+	path := []pathEntry{{n: &t.root}}
+	var prev []byte
+	first := true
+	for {
+		key, value, ok := next()
+		if !ok {
+			break
+		}
+		if !first {
+			switch c := bytes.Compare(prev, key); {
+			case c == 0:
+				return nil, fmt.Errorf("%w: %q", ErrDuplicateKey, key)
+			case c > 0:
+				return nil, fmt.Errorf("%w: %q after %q", ErrUnsortedKeys, key, prev)
+			}
+		}
+		first = false
+		if len(key) == 0 {
+			t.root.value = value
+			prev = prev[:0]
+			continue
+		}
+
+		l := commonPrefixLength(prev, key)
+		// Find the deepest node on the path whose key is a prefix of
+		// the common prefix of the previous and the current keys.
+		j := len(path) - 1
+		for path[j].depth > l {
+			j--
+		}
+		parent := path[j]
+		if j+1 < len(path) && parent.depth < l {
+			// Split the child of parent in the middle of its label.
+			child := path[j+1].n
+			k := l - parent.depth
+			mid := &node{
+				label:    child.label[:k:k],
+				value:    noValue,
+				children: []*node{child},
+			}
+			child.label = child.label[k:]
+			parent.n.children[len(parent.n.children)-1] = mid
+			path = append(path[:j+1], pathEntry{n: mid, depth: l})
+		} else {
+			path = path[:j+1]
+		}
+
+		top := path[len(path)-1].n
+		leaf := newNode(key[l:], value, nil)
+		top.children = append(top.children, leaf)
+		path = append(path, pathEntry{n: leaf, depth: len(key)})
+		prev = append(prev[:0], key...)
+	}
+	t.root.updateCount()
+	return t, nil
+}
@@ -0,0 +1,237 @@
+package radixtree
+
+import (
+	"bytes"
+	"math/bits"
+	"sort"
+)
+
+// CompactTree is a read-only radix tree in a succinct representation
+// for large static key sets.
+//
+// The shape of the tree is encoded in LOUDS (level-order unary degree
+// sequence), which takes about two bits per node. Labels of nodes are
+// concatenated in level order with a bit vector marking the end of each
+// label, and values are kept in a slice indexed by the rank in a bit
+// vector of nodes with values. Nodes are navigated with rank and select
+// operations on the bit vectors instead of pointers, so a CompactTree
+// takes a fraction of the memory of a Tree with the same keys.
+//
+// Unlike Tree, CompactTree is safe for concurrent use by multiple goroutines.
+type CompactTree struct {
+	// louds is "10" followed by the child count of each node in unary
+	// ("1" for each child, terminated by "0") in level order.
+	louds bitVector
+
+	// labels is the concatenation of labels of non-root nodes in level
+	// order, and labelEnds has bits set at the last byte of each label.
+	labels    []byte
+	labelEnds bitVector
+
+	// hasValue has bits set for nodes with values, and values holds
+	// the values in level order.
+	hasValue bitVector
+	values   []interface{}
+}
+
+// NewCompactTree returns a CompactTree which has the same keys and
+// values as t. Later changes to t are not reflected in the CompactTree.
+func NewCompactTree(t *Tree) *CompactTree {
+	var louds, labelEnds, hasValue bitVectorBuilder
+	c := &CompactTree{}
+	louds.append(true)
+	louds.append(false)
+	nodes := []*node{&t.root}
+	for i := 0; i < len(nodes); i++ {
+		n := nodes[i]
+		for range n.children {
+			louds.append(true)
+		}
+		louds.append(false)
+		nodes = append(nodes, n.children...)
+
+		if i > 0 {
+			c.labels = append(c.labels, n.label...)
+			for j := range n.label {
+				labelEnds.append(j == len(n.label)-1)
+			}
+		}
+		hasValue.append(n.hasValue())
+		if n.hasValue() {
+			c.values = append(c.values, n.value)
+		}
+	}
+	c.louds = louds.build()
+	c.labelEnds = labelEnds.build()
+	c.hasValue = hasValue.build()
+	return c
+}
+
+// Len returns the number of keys in the CompactTree.
+func (c *CompactTree) Len() int {
+	return len(c.values)
+}
+
+// children returns the id of the first child and the child count of
+// the node with the id.
+func (c *CompactTree) children(id int) (first, count int) {
+	start := c.louds.select0(id + 1)
+	end := c.louds.select0(id + 2)
+	return start - id, end - start - 1
+}
+
+// label returns the label of the non-root node with the id.
+func (c *CompactTree) label(id int) []byte {
+	start := 0
+	if id > 1 {
+		start = c.labelEnds.select1(id-1) + 1
+	}
+	end := c.labelEnds.select1(id) + 1
+	return c.labels[start:end:end]
+}
+
+func (c *CompactTree) value(id int) (value interface{}, ok bool) {
+	if !c.hasValue.get(id) {
+		return nil, false
+	}
+	return c.values[c.hasValue.rank1(id)], true
+}
+
+// child returns the id of the child of the node with the id whose
+// label starts with b.
+func (c *CompactTree) child(id int, b byte) (childID int, ok bool) {
+	first, count := c.children(id)
+	i := sort.Search(count, func(i int) bool {
+		return c.label(first + i)[0] >= b
+	})
+	if i == count || c.label(first + i)[0] != b {
+		return 0, false
+	}
+	return first + i, true
+}
+
+// Get returns the value for the key.
+func (c *CompactTree) Get(key []byte) (value interface{}, exists bool) {
+	id := 0
+	for len(key) > 0 {
+		var ok bool
+		id, ok = c.child(id, key[0])
+		if !ok {
+			return nil, false
+		}
+		label := c.label(id)
+		if !bytes.HasPrefix(key, label) {
+			return nil, false
+		}
+		key = key[len(label):]
+	}
+	return c.value(id)
+}
+
+// WalkPrefix calls fn for each key which has the specified prefix and
+// its value in lexicographic order of keys. The key passed to fn is
+// only valid until fn returns. WalkPrefix stops when fn returns false.
+func (c *CompactTree) WalkPrefix(prefix []byte, fn func(key []byte, value interface{}) bool) {
+	id := 0
+	key := make([]byte, 0, len(prefix)+64)
+	rest := prefix
+	for len(rest) > 0 {
+		var ok bool
+		id, ok = c.child(id, rest[0])
+		if !ok {
+			return
+		}
+		label := c.label(id)
+		l := commonPrefixLength(rest, label)
+		if l < len(rest) && l < len(label) {
+			return
+		}
+		key = append(key, label...)
+		rest = rest[l:]
+	}
+	c.walk(id, key, fn)
+}
+
+func (c *CompactTree) walk(id int, key []byte, fn func(key []byte, value interface{}) bool) bool {
+	if v, ok := c.value(id); ok && !fn(key, v) {
+		return false
+	}
+	first, count := c.children(id)
+	for i := first; i < first+count; i++ {
+		if !c.walk(i, append(key, c.label(i)...), fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// bitVector is an immutable bit vector supporting rank and select.
+type bitVector struct {
+	words []uint64
+	// ranks[i] is the number of ones in words[:i].
+	ranks []uint32
+}
+
+type bitVectorBuilder struct {
+	words []uint64
+	n     int
+}
+
+func (b *bitVectorBuilder) append(bit bool) {
+	if b.n%64 == 0 {
+		b.words = append(b.words, 0)
+	}
+	if bit {
+		b.words[b.n/64] |= 1 << uint(b.n%64)
+	}
+	b.n++
+}
+
+func (b *bitVectorBuilder) build() bitVector {
+	v := bitVector{
+		words: b.words,
+		ranks: make([]uint32, len(b.words)+1),
+	}
+	for i, w := range b.words {
+		v.ranks[i+1] = v.ranks[i] + uint32(bits.OnesCount64(w))
+	}
+	return v
+}
+
+func (v *bitVector) get(i int) bool {
+	return v.words[i/64]&(1<<uint(i%64)) != 0
+}
+
+// rank1 returns the number of ones in [0, i).
+func (v *bitVector) rank1(i int) int {
+	r := int(v.ranks[i/64])
+	if i%64 != 0 {
+		r += bits.OnesCount64(v.words[i/64] << uint(64-i%64))
+	}
+	return r
+}
+
+// select1 returns the position of the k'th one, counting from 1.
+func (v *bitVector) select1(k int) int {
+	// Find the last word whose preceding ones are less than k.
+	w := sort.Search(len(v.words), func(i int) bool {
+		return int(v.ranks[i+1]) >= k
+	})
+	return w*64 + selectInWord(v.words[w], k-int(v.ranks[w]))
+}
+
+// select0 returns the position of the k'th zero, counting from 1.
+func (v *bitVector) select0(k int) int {
+	w := sort.Search(len(v.words), func(i int) bool {
+		return (i+1)*64-int(v.ranks[i+1]) >= k
+	})
+	return w*64 + selectInWord(^v.words[w], k-(w*64-int(v.ranks[w])))
+}
+
+// selectInWord returns the position of the k'th one in w, counting from 1.
+func selectInWord(w uint64, k int) int {
+	for i := 1; i < k; i++ {
+		w &= w - 1
+	}
+	return bits.TrailingZeros64(w)
+}
@@ -0,0 +1,73 @@
+package radixtree
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DOTOptions is the options for WriteDOT.
+type DOTOptions struct {
+	// Prefix makes the graph rooted at the topmost node whose key has
+	// the prefix. The vertex of the root is labeled by the key of the node.
+	Prefix []byte
+
+	// MaxDepth limits the depth of vertices from the root of the graph
+	// if it is positive. Vertices whose children are omitted are drawn
+	// with dashed lines.
+	MaxDepth int
+}
+
+// dotEscaper escapes a string for a double-quoted ID in the DOT language.
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// WriteDOT writes the radix tree to w as a Graphviz digraph in the DOT
+// language. Each node is drawn as a vertex labeled by its label quoted
+// like String does, followed by its value and its type if the node has
+// a value. Pass nil to opts for default options.
+func (t *Tree) WriteDOT(w io.Writer, opts *DOTOptions) error {
+	if opts == nil {
+		opts = &DOTOptions{}
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString("digraph radixtree {\n\tnode [shape=box];\n")
+
+	root, key := t.subtree(opts.Prefix)
+	if root != nil {
+		var label []byte
+		if root == &t.root {
+			label = []byte{'.'}
+		} else {
+			label = strconv.AppendQuote(nil, string(key))
+		}
+		id := 0
+		var write func(n *node, label []byte, depth int) int
+		write = func(n *node, label []byte, depth int) int {
+			myID := id
+			id++
+			if n.hasValue() {
+				label = append(label, fmt.Sprintf(" %+v %T", n.value, n.value)...)
+			}
+			truncated := opts.MaxDepth > 0 && depth == opts.MaxDepth && len(n.children) > 0
+			fmt.Fprintf(bw, "\tn%d [label=\"%s\"", myID, dotEscaper.Replace(string(label)))
+			if truncated {
+				bw.WriteString(", style=dashed")
+			}
+			bw.WriteString("];\n")
+			if truncated {
+				return myID
+			}
+			for _, child := range n.children {
+				childID := write(child, strconv.AppendQuote(nil, string(child.label)), depth+1)
+				fmt.Fprintf(bw, "\tn%d -> n%d;\n", myID, childID)
+			}
+			return myID
+		}
+		write(root, label, 0)
+	}
+
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
@@ -0,0 +1,426 @@
+package radixtree
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Names of files in the directory of a DurableTree.
+const (
+	durableLogName      = "wal"
+	durableSnapshotName = "snapshot"
+)
+
+// Each record in the write-ahead log is framed as follows:
+//
+//	payload length (uint32, little endian)
+//	CRC-32C of the payload length (uint32, little endian)
+//	CRC-32C of the payload (uint32, little endian)
+//	payload
+//
+// The payload length has its own checksum, so a corrupted length is
+// detected instead of being taken for a record cut off at the end.
+//
+// The payload is the operation (1 byte, the value of Op), the key (or
+// the prefix for OpDeleteSubtree) with its length (uvarint), and for
+// OpSet the value encoded by the ValueCodec.
+const durableRecordHeaderSize = 12
+
+// minDurablePayloadSize is the size of the smallest payload, which is
+// of OpDelete or OpDeleteSubtree with an empty key.
+const minDurablePayloadSize = 2
+
+// maxDurableRecordSize is the limit of the payload length of a record
+// to detect corrupted lengths.
+const maxDurableRecordSize = 1 << 30
+
+// DurableOptions is the options for OpenDurable.
+type DurableOptions struct {
+	// Codec is used to encode values in the log and the snapshot.
+	// DefaultValueCodec is used if nil.
+	Codec ValueCodec
+
+	// SyncWrites makes each change call fsync on the log before it
+	// returns. Without this, changes survive crashes of the process
+	// but may be lost on crashes of the operating system.
+	SyncWrites bool
+}
+
+// DurableTree is a radix tree whose changes are appended to a
+// write-ahead log, so that it survives crashes.
+//
+// A DurableTree keeps two files in its directory: a snapshot of the
+// tree written by Tree.WriteTo, and the log of changes after the
+// snapshot. OpenDurable loads the snapshot and replays the log to
+// rebuild the tree. Compact writes a new snapshot and truncates the log.
+//
+// Like Tree, DurableTree is not goroutine safe.
+type DurableTree struct {
+	tree *Tree
+	dir  string
+	log  *os.File
+	opts DurableOptions
+	buf  []byte
+
+	// logSize is the size of the log, which is the offset of the end
+	// of the last complete record.
+	logSize int64
+}
+
+// OpenDurable opens the DurableTree in the directory dir, creating the
+// directory if it does not exist. Pass nil to opts for default options.
+//
+// A record at the end of the log which is truncated or has a wrong
+// checksum of the payload is considered an incomplete write at a crash
+// and removed. A record is considered truncated only if its header is
+// cut off or the header has a valid checksum. A header with a wrong
+// checksum is also considered an incomplete write if no record can
+// follow it, that is, if the rest of the log is shorter than the
+// smallest record or all zero. Corruption elsewhere causes an error
+// wrapping ErrChecksum or ErrInvalidBinary.
+func OpenDurable(dir string, opts *DurableOptions) (*DurableTree, error) {
+	d := &DurableTree{
+		tree: New(),
+		dir:  dir,
+	}
+	if opts != nil {
+		d.opts = *opts
+	}
+	d.tree.SetValueCodec(d.opts.Codec)
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := d.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	log, err := os.OpenFile(filepath.Join(dir, durableLogName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	end, err := d.replay(log)
+	if err != nil {
+		log.Close()
+		return nil, err
+	}
+	d.log = log
+	if err := d.truncateLog(end); err != nil {
+		log.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *DurableTree) loadSnapshot() error {
+	f, err := os.Open(filepath.Join(d.dir, durableSnapshotName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	if _, err := d.tree.ReadFrom(bufio.NewReader(f)); err != nil {
+		return fmt.Errorf("radixtree: failed to read snapshot: %w", err)
+	}
+	return nil
+}
+
+// replay applies records in the log to the tree and returns the offset
+// of the end of the last complete record.
+func (d *DurableTree) replay(log *os.File) (end int64, err error) {
+	fi, err := log.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := fi.Size()
+	r := bufio.NewReader(log)
+	var hdr [durableRecordHeaderSize]byte
+	var payload []byte
+	for {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return end, nil
+			}
+			return 0, err
+		}
+		if crc32.Checksum(hdr[0:4], crc32cTable) != binary.LittleEndian.Uint32(hdr[4:]) {
+			// A crash of the operating system may leave garbage or zeros
+			// at the end, which are considered an incomplete write
+			// unless a record may follow.
+			if size-end < durableRecordHeaderSize+minDurablePayloadSize {
+				return end, nil
+			}
+			zero, err := allZero(r)
+			if err != nil {
+				return 0, err
+			}
+			if zero && isZero(hdr[:]) {
+				return end, nil
+			}
+			return 0, fmt.Errorf("%w in log record header at offset %d", ErrChecksum, end)
+		}
+		l := binary.LittleEndian.Uint32(hdr[0:])
+		if l > maxDurableRecordSize {
+			return 0, fmt.Errorf("%w: too large log record at offset %d", ErrInvalidBinary, end)
+		}
+		recordEnd := end + durableRecordHeaderSize + int64(l)
+		if recordEnd > size {
+			// A record whose header was written but whose payload was not.
+			return end, nil
+		}
+		if cap(payload) < int(l) {
+			payload = make([]byte, l)
+		}
+		payload = payload[:l]
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return 0, err
+		}
+		if crc32.Checksum(payload, crc32cTable) != binary.LittleEndian.Uint32(hdr[8:]) {
+			if recordEnd == size {
+				return end, nil
+			}
+			return 0, fmt.Errorf("%w in log record at offset %d", ErrChecksum, end)
+		}
+		if err := d.apply(payload); err != nil {
+			return 0, fmt.Errorf("%w in log record at offset %d", err, end)
+		}
+		end = recordEnd
+	}
+}
+
+// allZero returns whether all the rest of r are zero bytes.
+func allZero(r io.Reader) (bool, error) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		if !isZero(buf[:n]) {
+			return false, nil
+		}
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *DurableTree) apply(payload []byte) error {
+	if len(payload) == 0 {
+		return fmt.Errorf("%w: empty record", ErrInvalidBinary)
+	}
+	op := Op(payload[0])
+	l, n := binary.Uvarint(payload[1:])
+	if n <= 0 || l > uint64(len(payload)-1-n) {
+		return fmt.Errorf("%w: bad key length", ErrInvalidBinary)
+	}
+	key := payload[1+n : 1+n+int(l)]
+	rest := payload[1+n+int(l):]
+	switch op {
+	case OpSet:
+		value, err := d.tree.valueCodec().DecodeValue(rest)
+		if err != nil {
+			return err
+		}
+		d.tree.Set(key, value)
+	case OpDelete:
+		d.tree.Delete(key)
+	case OpDeleteSubtree:
+		d.tree.DeleteSubtree(key)
+	default:
+		return fmt.Errorf("%w: unknown operation %d", ErrInvalidBinary, op)
+	}
+	return nil
+}
+
+// Tree returns the underlying radix tree for reading. Changes must be
+// made through the DurableTree, since changes made to the returned tree
+// directly are not logged.
+func (d *DurableTree) Tree() *Tree {
+	return d.tree
+}
+
+// Get returns the value for the key.
+func (d *DurableTree) Get(key []byte) (value interface{}, exists bool) {
+	return d.tree.Get(key)
+}
+
+// Set logs and sets the value for the key.
+func (d *DurableTree) Set(key []byte, value interface{}) error {
+	if err := d.appendRecord(OpSet, key, value); err != nil {
+		return err
+	}
+	d.tree.Set(key, value)
+	return nil
+}
+
+// Delete logs and deletes the key. The deletion of a key which does not
+// exist is not logged.
+func (d *DurableTree) Delete(key []byte) (deleted bool, err error) {
+	if _, exists := d.tree.Get(key); !exists {
+		return false, nil
+	}
+	if err := d.appendRecord(OpDelete, key, nil); err != nil {
+		return false, err
+	}
+	return d.tree.Delete(key), nil
+}
+
+// DeleteSubtree logs and deletes the subtree which has the specified
+// prefix. The deletion of a subtree which does not exist is not logged.
+func (d *DurableTree) DeleteSubtree(prefix []byte) (deleted bool, err error) {
+	if n, _ := d.tree.subtree(prefix); n == nil || (!n.hasValue() && len(n.children) == 0) {
+		return false, nil
+	}
+	if err := d.appendRecord(OpDeleteSubtree, prefix, nil); err != nil {
+		return false, err
+	}
+	return d.tree.DeleteSubtree(prefix), nil
+}
+
+func (d *DurableTree) appendRecord(op Op, key []byte, value interface{}) error {
+	if d.log == nil {
+		return errors.New("radixtree: DurableTree is closed")
+	}
+	buf := append(d.buf[:0], make([]byte, durableRecordHeaderSize)...)
+	buf = append(buf, byte(op))
+	buf = binary.AppendUvarint(buf, uint64(len(key)))
+	buf = append(buf, key...)
+	if op == OpSet {
+		var err error
+		if buf, err = d.tree.valueCodec().AppendValue(buf, value); err != nil {
+			return err
+		}
+	}
+	payload := buf[durableRecordHeaderSize:]
+	if len(payload) > maxDurableRecordSize {
+		return errors.New("radixtree: too large log record")
+	}
+	binary.LittleEndian.PutUint32(buf[0:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[4:], crc32.Checksum(buf[0:4], crc32cTable))
+	binary.LittleEndian.PutUint32(buf[8:], crc32.Checksum(payload, crc32cTable))
+	d.buf = buf
+	if _, err := d.log.Write(buf); err != nil {
+		// Remove a partially written record, which would be followed by
+		// later records and make the log corrupted.
+		if rerr := d.truncateLog(d.logSize); rerr != nil {
+			return fmt.Errorf("%w (failed to remove partial log record: %v)", err, rerr)
+		}
+		return err
+	}
+	d.logSize += int64(len(buf))
+	if d.opts.SyncWrites {
+		return d.log.Sync()
+	}
+	return nil
+}
+
+// truncateLog truncates the log to size and moves the offset there.
+func (d *DurableTree) truncateLog(size int64) error {
+	if err := d.log.Truncate(size); err != nil {
+		return err
+	}
+	if _, err := d.log.Seek(size, io.SeekStart); err != nil {
+		return err
+	}
+	d.logSize = size
+	return nil
+}
+
+// Sync commits the log to stable storage.
+func (d *DurableTree) Sync() error {
+	if d.log == nil {
+		return errors.New("radixtree: DurableTree is closed")
+	}
+	return d.log.Sync()
+}
+
+// Compact writes a snapshot of the tree and truncates the log.
+//
+// The snapshot is written to a temporary file and renamed, so a crash
+// during Compact leaves either the old or the new snapshot. If a crash
+// happens after the rename and before the truncation, the log is
+// replayed over the new snapshot on the next open, which results in the
+// same tree since the last change to each key in the log wins.
+func (d *DurableTree) Compact() error {
+	if d.log == nil {
+		return errors.New("radixtree: DurableTree is closed")
+	}
+	tmpName := filepath.Join(d.dir, durableSnapshotName+".tmp")
+	f, err := os.Create(tmpName)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	if _, err := d.tree.WriteTo(bw); err != nil {
+		f.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, filepath.Join(d.dir, durableSnapshotName)); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := syncDir(d.dir); err != nil {
+		return err
+	}
+
+	if err := d.truncateLog(0); err != nil {
+		return err
+	}
+	return d.log.Sync()
+}
+
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// Windows does not support syncing directories.
+	if err := f.Sync(); err != nil && runtime.GOOS != "windows" {
+		return err
+	}
+	return nil
+}
+
+// Close closes the log file. The DurableTree must not be used after Close.
+func (d *DurableTree) Close() error {
+	if d.log == nil {
+		return nil
+	}
+	err := d.log.Close()
+	d.log = nil
+	return err
+}
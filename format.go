@@ -0,0 +1,129 @@
+package radixtree
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// FormatOptions is the options for Format.
+// The zero value makes the same output as String.
+type FormatOptions struct {
+	// FormatValue formats values. If nil, values are formatted with
+	// the "%+v" verb followed by the type unless HideTypes is true.
+	FormatValue func(value interface{}) string
+
+	// HideTypes hides types of values formatted by the default
+	// value formatter.
+	HideTypes bool
+
+	// MaxDepth limits the depth of nodes to print if it is positive.
+	MaxDepth int
+
+	// MaxChildren limits the number of children to print for each
+	// node if it is positive.
+	MaxChildren int
+
+	// Prefix makes the output rooted at the topmost node whose key has
+	// the prefix. The root is printed as the quoted key of the node.
+	// Nothing is printed if no key has the prefix.
+	Prefix []byte
+
+	// Unicode makes lines drawn with Unicode box-drawing characters
+	// instead of ASCII characters.
+	Unicode bool
+}
+
+// Format writes the text representation of the radix tree to w.
+// Omitted children of nodes are shown as a line of "..." followed by
+// the number of them.
+func (t *Tree) Format(w io.Writer, opts FormatOptions) error {
+	n, key := t.subtree(opts.Prefix)
+	if n == nil {
+		return nil
+	}
+	var label []byte
+	if n == &t.root {
+		label = []byte{'.'}
+	} else {
+		label = strconv.AppendQuote(nil, string(key))
+	}
+	f := formatter{opts: opts}
+	f.format(n, label)
+	_, err := w.Write(f.buf)
+	return err
+}
+
+// Line drawing strings for formatter.
+var (
+	asciiLines   = [...]string{"|-- ", "`-- ", "|  ", "   "}
+	unicodeLines = [...]string{"├── ", "└── ", "│  ", "   "}
+)
+
+type formatter struct {
+	opts FormatOptions
+	buf  []byte
+}
+
+// format prints n and its descendants. label is printed as the label of n.
+func (f *formatter) format(n *node, label []byte) {
+	f.buf = append(f.buf, label...)
+	f.appendValue(n)
+	f.buf = append(f.buf, '\n')
+	f.formatChildren(n, nil, 1)
+}
+
+func (f *formatter) appendValue(n *node) {
+	if !n.hasValue() {
+		return
+	}
+	switch {
+	case f.opts.FormatValue != nil:
+		f.buf = append(f.buf, ' ')
+		f.buf = append(f.buf, f.opts.FormatValue(n.value)...)
+	case f.opts.HideTypes:
+		f.buf = append(f.buf, fmt.Sprintf(" %+v", n.value)...)
+	default:
+		f.buf = append(f.buf, fmt.Sprintf(" %+v %T", n.value, n.value)...)
+	}
+}
+
+func (f *formatter) formatChildren(p *node, leading []byte, depth int) {
+	lines := asciiLines
+	if f.opts.Unicode {
+		lines = unicodeLines
+	}
+	children := p.children
+	omitted := 0
+	if f.opts.MaxDepth > 0 && depth > f.opts.MaxDepth {
+		children, omitted = nil, len(children)
+	} else if f.opts.MaxChildren > 0 && len(children) > f.opts.MaxChildren {
+		children, omitted = children[:f.opts.MaxChildren], len(children)-f.opts.MaxChildren
+	}
+	for i, n := range children {
+		last := i == len(children)-1 && omitted == 0
+		f.buf = append(f.buf, leading...)
+		if last {
+			f.buf = append(f.buf, lines[1]...)
+		} else {
+			f.buf = append(f.buf, lines[0]...)
+		}
+		f.buf = strconv.AppendQuote(f.buf, string(n.label))
+		f.appendValue(n)
+		f.buf = append(f.buf, '\n')
+		if len(n.children) > 0 {
+			var leading2 []byte
+			if last {
+				leading2 = append(leading, lines[3]...)
+			} else {
+				leading2 = append(leading, lines[2]...)
+			}
+			f.formatChildren(n, leading2, depth+1)
+		}
+	}
+	if omitted > 0 {
+		f.buf = append(f.buf, leading...)
+		f.buf = append(f.buf, lines[1]...)
+		f.buf = append(f.buf, "... ("+strconv.Itoa(omitted)+" more)\n"...)
+	}
+}
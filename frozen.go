@@ -0,0 +1,381 @@
+package radixtree
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// The frozen format is a flat, pointer-free layout of a radix tree which
+// FrozenTree reads in place. All integers are little endian.
+//
+//	header (16 bytes):
+//		magic "RDXF", version (uint32), node count (uint32), reserved (uint32)
+//	node records (24 bytes each), in breadth-first order:
+//		data offset (uint64), label length (uint32), value length (uint32),
+//		index of the first child (uint32), child count (uint32)
+//	data:
+//		the label and the value of each node
+//
+// The data offset is relative to the start of the data and points to
+// the label, which is immediately followed by the value. The value length
+// is frozenNoValue for nodes without a value. The root is the node 0.
+// Children of a node have consecutive indexes, in the order of their labels.
+const (
+	frozenMagic      = "RDXF"
+	frozenVersion    = 1
+	frozenHeaderSize = 16
+	frozenRecordSize = 24
+	frozenNoValue    = 0xffffffff
+)
+
+// ErrInvalidFrozen is returned when opening or verifying malformed data
+// in the frozen format.
+var ErrInvalidFrozen = errors.New("radixtree: invalid frozen data")
+
+// Freeze writes the radix tree to w in the frozen format, which is read
+// by OpenFrozen and NewFrozenTree. Values are encoded with the codec set
+// with SetValueCodec.
+func (t *Tree) Freeze(w io.Writer) error {
+	codec := t.valueCodec()
+
+	// Collect nodes in breadth-first order.
+	nodes := []*node{&t.root}
+	for i := 0; i < len(nodes); i++ {
+		nodes = append(nodes, nodes[i].children...)
+	}
+	if uint64(len(nodes)) > frozenNoValue {
+		return errors.New("radixtree: too many nodes to freeze")
+	}
+
+	bw := bufio.NewWriter(w)
+	var hdr [frozenRecordSize]byte
+	copy(hdr[:], frozenMagic)
+	binary.LittleEndian.PutUint32(hdr[4:], frozenVersion)
+	binary.LittleEndian.PutUint32(hdr[8:], uint32(len(nodes)))
+	binary.LittleEndian.PutUint32(hdr[12:], 0)
+	if _, err := bw.Write(hdr[:frozenHeaderSize]); err != nil {
+		return err
+	}
+
+	// Values are encoded twice, first for lengths in records and then
+	// for data, to avoid holding all the encoded values in memory.
+	var buf []byte
+	var off uint64
+	firstChild := uint32(1)
+	for _, n := range nodes {
+		valueLen := uint32(frozenNoValue)
+		if n.hasValue() {
+			var err error
+			if buf, err = codec.AppendValue(buf[:0], n.value); err != nil {
+				return err
+			}
+			if uint64(len(buf)) >= frozenNoValue {
+				return errors.New("radixtree: too large value to freeze")
+			}
+			valueLen = uint32(len(buf))
+		}
+		binary.LittleEndian.PutUint64(hdr[0:], off)
+		binary.LittleEndian.PutUint32(hdr[8:], uint32(len(n.label)))
+		binary.LittleEndian.PutUint32(hdr[12:], valueLen)
+		binary.LittleEndian.PutUint32(hdr[16:], firstChild)
+		binary.LittleEndian.PutUint32(hdr[20:], uint32(len(n.children)))
+		if _, err := bw.Write(hdr[:]); err != nil {
+			return err
+		}
+		off += uint64(len(n.label))
+		if valueLen != frozenNoValue {
+			off += uint64(valueLen)
+		}
+		firstChild += uint32(len(n.children))
+	}
+
+	for _, n := range nodes {
+		if _, err := bw.Write(n.label); err != nil {
+			return err
+		}
+		if n.hasValue() {
+			var err error
+			if buf, err = codec.AppendValue(buf[:0], n.value); err != nil {
+				return err
+			}
+			if _, err := bw.Write(buf); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// FrozenTree is a read-only radix tree which reads data in the frozen
+// format written by Tree.Freeze in place, without deserializing it.
+// Values are the bytes encoded by the ValueCodec of the frozen Tree.
+//
+// Slices returned from FrozenTree refer to the underlying data, so they
+// must not be modified and must not be used after Close.
+//
+// Unlike Tree, FrozenTree is safe for concurrent use by multiple goroutines.
+type FrozenTree struct {
+	data      []byte
+	nodeCount uint32
+	dataOff   uint64
+	close     func() error
+}
+
+type frozenNode struct {
+	off        uint64
+	labelLen   uint32
+	valueLen   uint32
+	firstChild uint32
+	childCount uint32
+}
+
+// OpenFrozen opens a file written by Tree.Freeze. The file is mapped
+// into memory on platforms which support mmap, so only the pages
+// which are accessed are read from the file. Call Close after use.
+func OpenFrozen(name string) (*FrozenTree, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() < frozenHeaderSize {
+		return nil, fmt.Errorf("%w: file too small", ErrInvalidFrozen)
+	}
+	data, unmap, err := mmapFile(file, fi.Size())
+	if err != nil {
+		return nil, err
+	}
+	f, err := NewFrozenTree(data)
+	if err != nil {
+		unmap()
+		return nil, err
+	}
+	f.close = unmap
+	return f, nil
+}
+
+// NewFrozenTree returns a FrozenTree which reads data written by
+// Tree.Freeze. data must not be modified while the FrozenTree is used.
+//
+// NewFrozenTree only checks the header. Call Verify to check the whole data.
+func NewFrozenTree(data []byte) (*FrozenTree, error) {
+	if len(data) < frozenHeaderSize || string(data[:len(frozenMagic)]) != frozenMagic {
+		return nil, fmt.Errorf("%w: bad magic", ErrInvalidFrozen)
+	}
+	if v := binary.LittleEndian.Uint32(data[4:]); v != frozenVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidFrozen, v)
+	}
+	nodeCount := binary.LittleEndian.Uint32(data[8:])
+	dataOff := frozenHeaderSize + uint64(nodeCount)*frozenRecordSize
+	if nodeCount == 0 || dataOff > uint64(len(data)) {
+		return nil, fmt.Errorf("%w: bad node count %d", ErrInvalidFrozen, nodeCount)
+	}
+	return &FrozenTree{
+		data:      data,
+		nodeCount: nodeCount,
+		dataOff:   dataOff,
+	}, nil
+}
+
+// Close releases the memory mapped by OpenFrozen. It does nothing for
+// a FrozenTree returned from NewFrozenTree.
+func (f *FrozenTree) Close() error {
+	if f.close == nil {
+		return nil
+	}
+	err := f.close()
+	f.close = nil
+	f.data = nil
+	f.nodeCount = 0
+	return err
+}
+
+// node returns the record of the i'th node. ok is false if i is out of
+// range or the record is corrupted.
+func (f *FrozenTree) node(i uint32) (n frozenNode, ok bool) {
+	if i >= f.nodeCount {
+		return frozenNode{}, false
+	}
+	rec := f.data[frozenHeaderSize+uint64(i)*frozenRecordSize:]
+	n = frozenNode{
+		off:        binary.LittleEndian.Uint64(rec[0:]),
+		labelLen:   binary.LittleEndian.Uint32(rec[8:]),
+		valueLen:   binary.LittleEndian.Uint32(rec[12:]),
+		firstChild: binary.LittleEndian.Uint32(rec[16:]),
+		childCount: binary.LittleEndian.Uint32(rec[20:]),
+	}
+	end := n.off + uint64(n.labelLen)
+	if n.valueLen != frozenNoValue {
+		end += uint64(n.valueLen)
+	}
+	if end > uint64(len(f.data))-f.dataOff || end < n.off {
+		return frozenNode{}, false
+	}
+	if uint64(n.firstChild)+uint64(n.childCount) > uint64(f.nodeCount) {
+		return frozenNode{}, false
+	}
+	return n, true
+}
+
+func (f *FrozenTree) label(n frozenNode) []byte {
+	start := f.dataOff + n.off
+	return f.data[start : start+uint64(n.labelLen) : start+uint64(n.labelLen)]
+}
+
+func (f *FrozenTree) value(n frozenNode) (value []byte, ok bool) {
+	if n.valueLen == frozenNoValue {
+		return nil, false
+	}
+	start := f.dataOff + n.off + uint64(n.labelLen)
+	end := start + uint64(n.valueLen)
+	return f.data[start:end:end], true
+}
+
+// child returns the child of p whose label starts with c and its index.
+func (f *FrozenTree) child(p frozenNode, c byte) (idx uint32, n frozenNode, ok bool) {
+	i := sort.Search(int(p.childCount), func(i int) bool {
+		n, ok := f.node(p.firstChild + uint32(i))
+		if !ok || n.labelLen == 0 {
+			return true
+		}
+		return f.label(n)[0] >= c
+	})
+	if i == int(p.childCount) {
+		return 0, frozenNode{}, false
+	}
+	idx = p.firstChild + uint32(i)
+	n, ok = f.node(idx)
+	if !ok || n.labelLen == 0 || f.label(n)[0] != c {
+		return 0, frozenNode{}, false
+	}
+	return idx, n, true
+}
+
+// Get returns the value for the key.
+func (f *FrozenTree) Get(key []byte) (value []byte, exists bool) {
+	n, ok := f.node(0)
+	if !ok {
+		return nil, false
+	}
+	for len(key) > 0 {
+		_, n, ok = f.child(n, key[0])
+		if !ok || !bytes.HasPrefix(key, f.label(n)) {
+			return nil, false
+		}
+		key = key[n.labelLen:]
+	}
+	return f.value(n)
+}
+
+// LongestPrefix returns the longest key which is a prefix of the
+// specified key, and its value. The returned prefix is a subslice of key.
+func (f *FrozenTree) LongestPrefix(key []byte) (prefix, value []byte, ok bool) {
+	n, found := f.node(0)
+	if !found {
+		return nil, nil, false
+	}
+	consumed := 0
+	rest := key
+	for {
+		if v, hasValue := f.value(n); hasValue {
+			prefix, value, ok = key[:consumed], v, true
+		}
+		if len(rest) == 0 {
+			return prefix, value, ok
+		}
+		_, n, found = f.child(n, rest[0])
+		if !found || !bytes.HasPrefix(rest, f.label(n)) {
+			return prefix, value, ok
+		}
+		consumed += int(n.labelLen)
+		rest = rest[n.labelLen:]
+	}
+}
+
+// WalkPrefix calls fn for each key which has the specified prefix and
+// its value in lexicographic order of keys. The key passed to fn is
+// only valid until fn returns. WalkPrefix stops when fn returns false.
+func (f *FrozenTree) WalkPrefix(prefix []byte, fn func(key, value []byte) bool) {
+	n, ok := f.node(0)
+	if !ok {
+		return
+	}
+	var idx uint32
+	key := make([]byte, 0, len(prefix)+64)
+	rest := prefix
+	for len(rest) > 0 {
+		idx, n, ok = f.child(n, rest[0])
+		if !ok {
+			return
+		}
+		label := f.label(n)
+		l := commonPrefixLength(rest, label)
+		if l < len(rest) && l < len(label) {
+			return
+		}
+		key = append(key, label...)
+		rest = rest[l:]
+	}
+	f.walk(idx, n, key, fn)
+}
+
+// walk walks the subtree of the idx'th node n. Children with indexes
+// not greater than idx are skipped, so corrupted data cannot make walk
+// loop forever.
+func (f *FrozenTree) walk(idx uint32, n frozenNode, key []byte, fn func(key, value []byte) bool) bool {
+	if v, ok := f.value(n); ok && !fn(key, v) {
+		return false
+	}
+	for i := uint32(0); i < n.childCount; i++ {
+		childIdx := n.firstChild + i
+		child, ok := f.node(childIdx)
+		if !ok || childIdx <= idx {
+			continue
+		}
+		if !f.walk(childIdx, child, append(key, f.label(child)...), fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// Verify checks all node records refer to valid ranges of data and
+// children are sorted.
+func (f *FrozenTree) Verify() error {
+	for i := uint32(0); i < f.nodeCount; i++ {
+		n, ok := f.node(i)
+		if !ok {
+			return fmt.Errorf("%w: bad record of node %d", ErrInvalidFrozen, i)
+		}
+		if i > 0 && n.labelLen == 0 {
+			return fmt.Errorf("%w: empty label of node %d", ErrInvalidFrozen, i)
+		}
+		if n.childCount > 0 && n.firstChild <= i {
+			return fmt.Errorf("%w: bad first child of node %d", ErrInvalidFrozen, i)
+		}
+		var prev []byte
+		for j := uint32(0); j < n.childCount; j++ {
+			child, ok := f.node(n.firstChild + j)
+			if !ok || child.labelLen == 0 {
+				return fmt.Errorf("%w: bad child of node %d", ErrInvalidFrozen, i)
+			}
+			label := f.label(child)
+			if prev != nil && prev[0] >= label[0] {
+				return fmt.Errorf("%w: unsorted children of node %d", ErrInvalidFrozen, i)
+			}
+			prev = label
+		}
+	}
+	return nil
+}
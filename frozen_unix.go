@@ -0,0 +1,19 @@
+//go:build unix
+
+package radixtree
+
+import (
+	"os"
+	"syscall"
+)
+
+func mmapFile(f *os.File, size int64) (data []byte, unmap func() error, err error) {
+	if int64(int(size)) != size {
+		return nil, nil, syscall.EFBIG
+	}
+	data, err = syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, &os.PathError{Op: "mmap", Path: f.Name(), Err: err}
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
@@ -0,0 +1,95 @@
+package radixtree
+
+// FuzzyMatch is a key returned from FuzzySearch.
+type FuzzyMatch struct {
+	Key      []byte
+	Value    interface{}
+	Distance int
+}
+
+// FuzzySearch returns keys whose Levenshtein distances from the
+// specified key are at most maxDistance in lexicographic order of keys,
+// with their values and distances. Distances are counted in bytes.
+//
+// FuzzySearch walks the radix tree keeping a row of the dynamic
+// programming table for each byte of labels on the path, and skips
+// subtrees where all the values in the row exceed maxDistance.
+func (t *Tree) FuzzySearch(key []byte, maxDistance int) []FuzzyMatch {
+	if maxDistance < 0 {
+		return nil
+	}
+	s := fuzzySearcher{
+		key:         key,
+		maxDistance: maxDistance,
+		rows:        make([]int, len(key)+1, (len(key)+1)*16),
+	}
+	for j := range s.rows {
+		s.rows[j] = j
+	}
+	s.search(&t.root)
+	return s.matches
+}
+
+type fuzzySearcher struct {
+	key         []byte
+	maxDistance int
+
+	// rows holds a row for each byte of the current path, where the
+	// row for the i-th byte starts at i*(len(key)+1), and the first
+	// row is for the empty prefix.
+	rows    []int
+	path    []byte
+	matches []FuzzyMatch
+}
+
+func (s *fuzzySearcher) search(n *node) {
+	if n.hasValue() {
+		if d := s.rows[len(s.rows)-1]; d <= s.maxDistance {
+			s.matches = append(s.matches, FuzzyMatch{
+				Key:      append([]byte{}, s.path...),
+				Value:    n.value,
+				Distance: d,
+			})
+		}
+	}
+	for _, child := range n.children {
+		pathLen, rowsLen := len(s.path), len(s.rows)
+		if s.appendRows(child.label) {
+			s.search(child)
+		}
+		s.path, s.rows = s.path[:pathLen], s.rows[:rowsLen]
+	}
+}
+
+// appendRows appends rows for bytes in label to rows, and returns false
+// if distances for all keys under the label exceed maxDistance.
+func (s *fuzzySearcher) appendRows(label []byte) bool {
+	width := len(s.key) + 1
+	for _, c := range label {
+		s.path = append(s.path, c)
+		prev := len(s.rows) - width
+		s.rows = append(s.rows, s.rows[prev]+1)
+		min := s.rows[len(s.rows)-1]
+		for j := 1; j < width; j++ {
+			cost := 1
+			if s.key[j-1] == c {
+				cost = 0
+			}
+			d := s.rows[prev+j-1] + cost
+			if v := s.rows[prev+j] + 1; v < d {
+				d = v
+			}
+			if v := s.rows[len(s.rows)-1] + 1; v < d {
+				d = v
+			}
+			s.rows = append(s.rows, d)
+			if d < min {
+				min = d
+			}
+		}
+		if min > s.maxDistance {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+//go:build go1.23
+
+package radixtree
+
+import (
+	"bytes"
+	"iter"
+)
+
+// All returns an iterator over keys and values in the radix tree in
+// lexicographic order of keys.
+//
+// The key passed to the loop body is only valid until the next
+// iteration and must not be modified. The radix tree may be modified
+// in the loop body; the iteration continues from the current key in
+// the modified tree, so keys added after the current key are visited
+// and deleted keys are not. See Iterator for details.
+func (t *Tree) All() iter.Seq2[[]byte, interface{}] {
+	return func(yield func([]byte, interface{}) bool) {
+		it := t.Iterator()
+		for it.Next() {
+			if !yield(it.Key(), it.Value()) {
+				return
+			}
+		}
+	}
+}
+
+// Backward returns an iterator over keys and values in the radix tree
+// in reverse lexicographic order of keys. Modifications in the loop
+// body are handled as All does, in the reverse direction.
+func (t *Tree) Backward() iter.Seq2[[]byte, interface{}] {
+	return func(yield func([]byte, interface{}) bool) {
+		it := t.Iterator()
+		for it.Prev() {
+			if !yield(it.Key(), it.Value()) {
+				return
+			}
+		}
+	}
+}
+
+// Prefix returns an iterator over keys which have the specified prefix
+// and their values in lexicographic order of keys. Modifications in
+// the loop body are handled as All does.
+func (t *Tree) Prefix(prefix []byte) iter.Seq2[[]byte, interface{}] {
+	return func(yield func([]byte, interface{}) bool) {
+		it := t.Iterator()
+		for ok := it.SeekPrefix(prefix); ok; ok = it.Next() {
+			if !yield(it.Key(), it.Value()) {
+				return
+			}
+		}
+	}
+}
+
+// Range returns an iterator over keys in the interval [start, end) and
+// their values in lexicographic order of keys. A nil end means no upper
+// bound. Modifications in the loop body are handled as All does.
+func (t *Tree) Range(start, end []byte) iter.Seq2[[]byte, interface{}] {
+	return func(yield func([]byte, interface{}) bool) {
+		it := t.Iterator()
+		for ok := it.Seek(start); ok; ok = it.Next() {
+			if end != nil && bytes.Compare(it.Key(), end) >= 0 {
+				return
+			}
+			if !yield(it.Key(), it.Value()) {
+				return
+			}
+		}
+	}
+}
+
+// Keys returns an iterator over keys in the radix tree in lexicographic
+// order. Keys are only valid until the next iteration as in All.
+func (t *Tree) Keys() iter.Seq[[]byte] {
+	return func(yield func([]byte) bool) {
+		for k := range t.All() {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns an iterator over values in the radix tree in
+// lexicographic order of their keys.
+func (t *Tree) Values() iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		for _, v := range t.All() {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+//go:build go1.23
+
+package radixtree_test
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hnakamur/radixtree"
+)
+
+func TestAll(t *testing.T) {
+	tree, keys := randomTree(1, 200)
+
+	var got []string
+	for k, v := range tree.All() {
+		if v != string(k) {
+			t.Errorf("value unmatch, key=%q, value=%v", k, v)
+		}
+		got = append(got, string(k))
+	}
+	if !reflect.DeepEqual(got, keys) {
+		t.Errorf("All result unmatch, got=%q, want=%q", got, keys)
+	}
+
+	got = nil
+	for k := range tree.Keys() {
+		got = append(got, string(k))
+		if len(got) == 3 {
+			break
+		}
+	}
+	if !reflect.DeepEqual(got, keys[:3]) {
+		t.Errorf("Keys result unmatch after break, got=%q, want=%q", got, keys[:3])
+	}
+
+	got = nil
+	for v := range tree.Values() {
+		got = append(got, v.(string))
+	}
+	if !reflect.DeepEqual(got, keys) {
+		t.Errorf("Values result unmatch, got=%q, want=%q", got, keys)
+	}
+
+	got = nil
+	for k := range tree.Backward() {
+		got = append(got, string(k))
+	}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, keys) {
+		t.Errorf("Backward result unmatch, got=%q, want=%q", got, keys)
+	}
+
+	var want []string
+	for _, k := range keys {
+		if strings.HasPrefix(k, "ab") {
+			want = append(want, k)
+		}
+	}
+	got = nil
+	for k := range tree.Prefix([]byte("ab")) {
+		got = append(got, string(k))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Prefix result unmatch, got=%q, want=%q", got, want)
+	}
+
+	want = nil
+	for _, k := range keys {
+		if k >= "ab" && k < "bb" {
+			want = append(want, k)
+		}
+	}
+	got = nil
+	for k := range tree.Range([]byte("ab"), []byte("bb")) {
+		got = append(got, string(k))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Range result unmatch, got=%q, want=%q", got, want)
+	}
+}
+
+func TestAllWithModification(t *testing.T) {
+	tree := radixtree.New()
+	for _, k := range []string{"a", "b", "c", "d"} {
+		tree.Set([]byte(k), k)
+	}
+	var got []string
+	for k := range tree.All() {
+		got = append(got, string(k))
+		switch string(k) {
+		case "a":
+			tree.Delete([]byte("b"))
+			tree.Set([]byte("bb"), "bb")
+		case "bb":
+			tree.DeleteSubtree([]byte("bb"))
+			tree.Set([]byte("0"), "0")
+		case "c":
+			tree.Delete([]byte("c"))
+		}
+	}
+	want := []string{"a", "bb", "c", "d"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("result unmatch, got=%q, want=%q", got, want)
+	}
+
+	got = nil
+	for k := range tree.Backward() {
+		got = append(got, string(k))
+		if string(k) == "d" {
+			tree.Set([]byte("ca"), "ca")
+			tree.Delete([]byte("a"))
+		}
+	}
+	want = []string{"d", "ca", "0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Backward result unmatch, got=%q, want=%q", got, want)
+	}
+}
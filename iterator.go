@@ -0,0 +1,278 @@
+package radixtree
+
+import "bytes"
+
+// Iterator is a cursor over keys in a radix tree in lexicographic order.
+//
+// An Iterator keeps the path from the root to the current node as an
+// explicit stack of nodes and child indexes, so moving to the next or
+// previous key costs the depth of the tree at most, and usually less.
+//
+// A new Iterator is not positioned. Next moves it to the first key and
+// Prev moves it to the last key in that case, so you can iterate all
+// keys with the following loop:
+//
+//	it := tree.Iterator()
+//	for it.Next() {
+//		fmt.Println(it.Key(), it.Value())
+//	}
+//
+// The radix tree may be modified while an Iterator is used. Next and
+// Prev detect the modification and continue from the current key in
+// the modified tree, so keys added after the current position in the
+// direction of the iteration are visited and deleted keys are not.
+// Key and Value keep returning the ones at the current position.
+type Iterator struct {
+	t *Tree
+
+	// prefix is the prefix passed to SeekPrefix. root is the root of
+	// the subtree for prefix, and rootKey is the key of root.
+	prefix  []byte
+	root    *node
+	rootKey []byte
+
+	// version is the version of the tree when the stack was built.
+	version uint64
+
+	stack []iteratorFrame
+	key   []byte
+	value interface{}
+	state iteratorState
+}
+
+type iteratorFrame struct {
+	n *node
+	// i is the index of the child of n in the next frame.
+	i int
+	// keyLen is the length of the key of n.
+	keyLen int
+}
+
+type iteratorState int
+
+const (
+	iteratorUnpositioned iteratorState = iota
+	iteratorValid
+	iteratorExhausted
+)
+
+// Iterator returns a new Iterator over all keys in the radix tree.
+func (t *Tree) Iterator() *Iterator {
+	return &Iterator{
+		t:    t,
+		root: &t.root,
+	}
+}
+
+// Valid returns whether the Iterator is positioned at a key.
+func (it *Iterator) Valid() bool {
+	return it.state == iteratorValid
+}
+
+// Key returns the key at the current position. The returned slice is
+// only valid until the Iterator is moved, and must not be modified.
+// Key must be called only when Valid returns true.
+func (it *Iterator) Key() []byte {
+	return it.key
+}
+
+// Value returns the value at the current position.
+// Value must be called only when Valid returns true.
+func (it *Iterator) Value() interface{} {
+	return it.value
+}
+
+// SeekPrefix restricts the iteration to keys which have the specified
+// prefix and moves to the first of them. It returns whether such a key
+// exists. Passing nil or an empty slice removes the restriction.
+func (it *Iterator) SeekPrefix(prefix []byte) bool {
+	it.prefix = append(it.prefix[:0], prefix...)
+	it.root, it.rootKey = it.t.subtree(prefix)
+	return it.First()
+}
+
+// First moves to the first key and returns whether it exists.
+func (it *Iterator) First() bool {
+	if !it.reset() {
+		return false
+	}
+	return it.descendFirst()
+}
+
+// Last moves to the last key and returns whether it exists.
+func (it *Iterator) Last() bool {
+	if !it.reset() {
+		return false
+	}
+	return it.descendLast()
+}
+
+// Seek moves to the first key which is greater than or equal to key,
+// and returns whether such a key exists.
+func (it *Iterator) Seek(key []byte) bool {
+	if !it.reset() {
+		return false
+	}
+	if bytes.Compare(key, it.rootKey) <= 0 {
+		return it.descendFirst()
+	}
+	if !bytes.HasPrefix(key, it.rootKey) {
+		// All keys under root are less than key.
+		return it.exhaust()
+	}
+	rest := key[len(it.rootKey):]
+	for {
+		top := &it.stack[len(it.stack)-1]
+		n := top.n
+		if len(rest) == 0 {
+			return it.descendFirst()
+		}
+		i := n.indexForPrefix(rest)
+		if i == len(n.children) {
+			return it.skipSubtree()
+		}
+		child := n.children[i]
+		l := commonPrefixLength(rest, child.label)
+		switch {
+		case l == len(child.label):
+			it.push(i)
+			rest = rest[l:]
+		case l == len(rest) || child.label[l] > rest[l]:
+			it.push(i)
+			return it.descendFirst()
+		default:
+			it.push(i)
+			return it.skipSubtree()
+		}
+	}
+}
+
+// Next moves to the next key and returns whether it exists.
+func (it *Iterator) Next() bool {
+	switch it.state {
+	case iteratorUnpositioned:
+		return it.First()
+	case iteratorExhausted:
+		return false
+	}
+	if it.version != it.t.version {
+		cur := it.resync()
+		if !it.Seek(cur) {
+			return false
+		}
+		if !bytes.Equal(it.key, cur) {
+			return true
+		}
+	}
+	if len(it.stack[len(it.stack)-1].n.children) > 0 {
+		it.push(0)
+		return it.descendFirst()
+	}
+	return it.skipSubtree()
+}
+
+// Prev moves to the previous key and returns whether it exists.
+func (it *Iterator) Prev() bool {
+	switch it.state {
+	case iteratorUnpositioned:
+		return it.Last()
+	case iteratorExhausted:
+		return false
+	}
+	if it.version != it.t.version {
+		cur := it.resync()
+		if !it.Seek(cur) {
+			return it.Last()
+		}
+	}
+	for len(it.stack) > 1 {
+		it.pop()
+		parent := &it.stack[len(it.stack)-1]
+		if parent.i > 0 {
+			it.push(parent.i - 1)
+			return it.descendLast()
+		}
+		if parent.n.hasValue() {
+			return it.valid()
+		}
+	}
+	return it.exhaust()
+}
+
+// resync finds the subtree for the prefix again after the tree is
+// modified, and returns a copy of the current key.
+func (it *Iterator) resync() []byte {
+	cur := append([]byte{}, it.key...)
+	it.root, it.rootKey = it.t.subtree(it.prefix)
+	return cur
+}
+
+// reset makes the stack hold only the root. It returns false if there
+// are no keys to iterate.
+func (it *Iterator) reset() bool {
+	if it.root == nil || (!it.root.hasValue() && len(it.root.children) == 0) {
+		it.stack = it.stack[:0]
+		return it.exhaust()
+	}
+	it.version = it.t.version
+	it.key = append(it.key[:0], it.rootKey...)
+	it.stack = append(it.stack[:0], iteratorFrame{n: it.root, keyLen: len(it.key)})
+	return true
+}
+
+func (it *Iterator) push(i int) {
+	top := &it.stack[len(it.stack)-1]
+	top.i = i
+	child := top.n.children[i]
+	it.key = append(it.key, child.label...)
+	it.stack = append(it.stack, iteratorFrame{n: child, keyLen: len(it.key)})
+}
+
+func (it *Iterator) pop() {
+	it.stack = it.stack[:len(it.stack)-1]
+	it.key = it.key[:it.stack[len(it.stack)-1].keyLen]
+}
+
+// descendFirst moves to the first key in the subtree of the top node.
+func (it *Iterator) descendFirst() bool {
+	for !it.stack[len(it.stack)-1].n.hasValue() {
+		it.push(0)
+	}
+	return it.valid()
+}
+
+// descendLast moves to the last key in the subtree of the top node.
+func (it *Iterator) descendLast() bool {
+	for {
+		n := it.stack[len(it.stack)-1].n
+		if len(n.children) == 0 {
+			return it.valid()
+		}
+		it.push(len(n.children) - 1)
+	}
+}
+
+// skipSubtree moves to the first key after the subtree of the top node.
+func (it *Iterator) skipSubtree() bool {
+	for len(it.stack) > 1 {
+		it.pop()
+		parent := &it.stack[len(it.stack)-1]
+		if parent.i+1 < len(parent.n.children) {
+			it.push(parent.i + 1)
+			return it.descendFirst()
+		}
+	}
+	return it.exhaust()
+}
+
+func (it *Iterator) valid() bool {
+	it.value = it.stack[len(it.stack)-1].n.value
+	it.state = iteratorValid
+	return true
+}
+
+func (it *Iterator) exhaust() bool {
+	it.value = nil
+	it.state = iteratorExhausted
+	return false
+}
@@ -0,0 +1,197 @@
+package radixtree
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// jsonBase64KeyPrefix marks a key encoded in base64 in the flat JSON form.
+const jsonBase64KeyPrefix = "\x00base64:"
+
+// MarshalJSON implements the json.Marshaler interface. The radix tree
+// is encoded as a flat JSON object of keys and values in lexicographic
+// order of keys. Values are encoded with json.Marshal.
+//
+// Since JSON strings cannot hold arbitrary bytes, a key which is not
+// valid UTF-8 is encoded as "\u0000base64:" followed by the key in the
+// standard base64 encoding. A key which starts with "\x00base64:" is
+// encoded in the same way so that UnmarshalJSON can tell them apart.
+func (t *Tree) MarshalJSON() ([]byte, error) {
+	buf := []byte{'{'}
+	var err error
+	t.root.walk(nil, func(key []byte, value interface{}) bool {
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		k := string(key)
+		if !utf8.Valid(key) || bytes.HasPrefix(key, []byte(jsonBase64KeyPrefix)) {
+			k = jsonBase64KeyPrefix + base64.StdEncoding.EncodeToString(key)
+		}
+		var b []byte
+		if b, err = json.Marshal(k); err != nil {
+			return false
+		}
+		buf = append(append(buf, b...), ':')
+		if b, err = json.Marshal(value); err != nil {
+			return false
+		}
+		buf = append(buf, b...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, '}'), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. It replaces
+// all keys in the radix tree with the ones in a flat JSON object written
+// by MarshalJSON. Keys encoded in base64 by MarshalJSON are decoded.
+// Values are decoded with json.Unmarshal into interface{}.
+func (t *Tree) UnmarshalJSON(data []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	if m == nil {
+		return errors.New("radixtree: cannot unmarshal JSON null into Tree")
+	}
+	nt := New()
+	for k, raw := range m {
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+		key := []byte(k)
+		if strings.HasPrefix(k, jsonBase64KeyPrefix) {
+			var err error
+			key, err = base64.StdEncoding.DecodeString(k[len(jsonBase64KeyPrefix):])
+			if err != nil {
+				return fmt.Errorf("radixtree: invalid base64 key in JSON object: %w", err)
+			}
+		}
+		nt.set(key, v)
+	}
+	t.replaceRoot(&nt.root)
+	return nil
+}
+
+// NestedJSON marshals and unmarshals a radix tree in a nested JSON
+// form which mirrors the structure of nodes, like String does.
+//
+// Each node is encoded as a JSON object with the following members:
+//
+//	"label"       the label of the node, omitted for the root
+//	"labelBase64" the label encoded in base64, used instead of "label"
+//	              when the label is not valid UTF-8
+//	"value"       the value of the node, omitted if the node has no value
+//	"children"    the array of the child nodes, omitted if the node has no children
+//
+// Labels may not be valid UTF-8 even if all keys are, since a label can
+// end in the middle of a multibyte character.
+type NestedJSON struct {
+	Tree *Tree
+}
+
+type jsonNode struct {
+	Label       string          `json:"label,omitempty"`
+	LabelBase64 []byte          `json:"labelBase64,omitempty"`
+	Value       json.RawMessage `json:"value,omitempty"`
+	Children    []*jsonNode     `json:"children,omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (j NestedJSON) MarshalJSON() ([]byte, error) {
+	jn, err := j.Tree.root.toJSONNode()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jn)
+}
+
+func (n *node) toJSONNode() (*jsonNode, error) {
+	jn := &jsonNode{}
+	if utf8.Valid(n.label) {
+		jn.Label = string(n.label)
+	} else {
+		jn.LabelBase64 = n.label
+	}
+	if n.hasValue() {
+		v, err := json.Marshal(n.value)
+		if err != nil {
+			return nil, err
+		}
+		jn.Value = v
+	}
+	if len(n.children) > 0 {
+		jn.Children = make([]*jsonNode, len(n.children))
+		for i, child := range n.children {
+			c, err := child.toJSONNode()
+			if err != nil {
+				return nil, err
+			}
+			jn.Children[i] = c
+		}
+	}
+	return jn, nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. It replaces
+// all keys in the radix tree with the ones in the nested JSON form.
+// It returns an error if the structure of nodes is not the one which
+// Set, Delete and DeleteSubtree would make.
+func (j NestedJSON) UnmarshalJSON(data []byte) error {
+	var jn jsonNode
+	if err := json.Unmarshal(data, &jn); err != nil {
+		return err
+	}
+	root, err := jn.toNode(true)
+	if err != nil {
+		return err
+	}
+	j.Tree.replaceRoot(root)
+	return nil
+}
+
+func (jn *jsonNode) toNode(isRoot bool) (*node, error) {
+	var label []byte
+	if jn.Label != "" {
+		if len(jn.LabelBase64) > 0 {
+			return nil, errors.New("radixtree: invalid nested JSON: both label and labelBase64 are set")
+		}
+		label = []byte(jn.Label)
+	} else if len(jn.LabelBase64) > 0 {
+		label = jn.LabelBase64
+	}
+	n := &node{value: noValue}
+	if jn.Value != nil {
+		var v interface{}
+		if err := json.Unmarshal(jn.Value, &v); err != nil {
+			return nil, err
+		}
+		n.value = v
+	}
+	if len(jn.Children) > 0 {
+		n.children = make([]*node, len(jn.Children))
+		for i, c := range jn.Children {
+			if c == nil {
+				return nil, errors.New("radixtree: invalid nested JSON: null child")
+			}
+			child, err := c.toNode(false)
+			if err != nil {
+				return nil, err
+			}
+			n.children[i] = child
+		}
+	}
+	if err := n.validate(label, isRoot); err != nil {
+		return nil, fmt.Errorf("radixtree: invalid nested JSON: %v", err)
+	}
+	n.label = label
+	return n, nil
+}
@@ -0,0 +1,145 @@
+package radixtree
+
+import "bytes"
+
+// List returns keys which have the specified prefix and are greater
+// than startAfter, and their values in lexicographic order of keys.
+// Pass nil to startAfter to list from the first key.
+//
+// At most limit keys are returned if limit is positive. If there are
+// more keys, next is the last returned key, which can be passed to
+// startAfter to get the next page. Otherwise next is nil.
+func (t *Tree) List(prefix, startAfter []byte, limit int) (keys [][]byte, values []interface{}, next []byte) {
+	keys, values, _, next = t.ListWithDelimiter(prefix, startAfter, nil, limit)
+	return keys, values, next
+}
+
+// ListWithDelimiter is like List, but keys which contain the delimiter
+// after the prefix are grouped by the part up to the first occurrence of
+// the delimiter, which is returned in commonPrefixes in place of the
+// keys, like CommonPrefixes of listing objects in Amazon S3. Keys in a
+// group are skipped at once without being visited.
+//
+// Keys and common prefixes are counted together for limit and compared
+// together with startAfter, so next may be a common prefix. Passing it
+// to startAfter skips the whole group. If delimiter is empty, keys are
+// not grouped and commonPrefixes is nil.
+func (t *Tree) ListWithDelimiter(prefix, startAfter, delimiter []byte, limit int) (keys [][]byte, values []interface{}, commonPrefixes [][]byte, next []byte) {
+	it := t.Iterator()
+	ok := it.SeekPrefix(prefix)
+	if ok && startAfter != nil {
+		ok = it.Seek(startAfter)
+		if ok && bytes.Equal(it.Key(), startAfter) {
+			ok = it.Next()
+		}
+	}
+
+	var last []byte
+	count := 0
+	for ok {
+		key := it.Key()
+		if len(delimiter) > 0 {
+			if i := bytes.Index(key[len(prefix):], delimiter); i >= 0 {
+				cp := append([]byte{}, key[:len(prefix)+i+len(delimiter)]...)
+				// The group of startAfter or of a key before it may be
+				// found first, which was returned in the previous page.
+				if startAfter == nil || bytes.Compare(cp, startAfter) > 0 {
+					if limit > 0 && count == limit {
+						return keys, values, commonPrefixes, last
+					}
+					commonPrefixes = append(commonPrefixes, cp)
+					count++
+					last = cp
+				}
+				end := prefixEnd(cp)
+				ok = end != nil && it.Seek(end)
+				continue
+			}
+		}
+		if limit > 0 && count == limit {
+			return keys, values, commonPrefixes, last
+		}
+		last = append([]byte{}, key...)
+		keys = append(keys, last)
+		values = append(values, it.Value())
+		count++
+		ok = it.Next()
+	}
+	return keys, values, commonPrefixes, nil
+}
+
+// prefixEnd returns the least key which is greater than all keys having
+// the prefix, or nil if there is no such key.
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for len(end) > 0 && end[len(end)-1] == 0xff {
+		end = end[:len(end)-1]
+	}
+	if len(end) == 0 {
+		return nil
+	}
+	end[len(end)-1]++
+	return end
+}
+
+// PathSegment is a segment of keys returned from Children.
+type PathSegment struct {
+	// Segment is the part of keys after the prefix up to the separator,
+	// not including the separator.
+	Segment []byte
+
+	// IsKey tells whether the prefix followed by Segment is a key.
+	IsKey bool
+
+	// HasDescendants tells whether there are keys which have the prefix
+	// followed by Segment and the separator.
+	HasDescendants bool
+}
+
+// Children returns distinct segments of keys under the specified prefix
+// in lexicographic order, where a segment is the part of a key after the
+// prefix up to the first sep, like entries of a directory for paths. The
+// prefix itself is not returned even if it is a key.
+//
+// Once a segment is found to have descendants, its subtree is skipped
+// without being visited.
+func (t *Tree) Children(prefix []byte, sep byte) []PathSegment {
+	n, key := t.subtree(prefix)
+	if n == nil {
+		return nil
+	}
+	rest := key[len(prefix):]
+	if i := bytes.IndexByte(rest, sep); i >= 0 {
+		return []PathSegment{{Segment: rest[:i:i], HasDescendants: true}}
+	}
+	var segments []PathSegment
+	n.pathSegments(append([]byte{}, rest...), sep, &segments)
+	return segments
+}
+
+// pathSegments appends segments in the subtree rooted at n to segments.
+// rest is the key of n after the prefix, which does not contain sep.
+func (n *node) pathSegments(rest []byte, sep byte, segments *[]PathSegment) {
+	isKey := n.hasValue() && len(rest) > 0
+	i := n.indexForPrefix([]byte{sep})
+	hasDescendants := i < len(n.children) && n.children[i].label[0] == sep
+	if isKey || hasDescendants {
+		*segments = append(*segments, PathSegment{
+			Segment:        append([]byte{}, rest...),
+			IsKey:          isKey,
+			HasDescendants: hasDescendants,
+		})
+	}
+	for _, child := range n.children {
+		switch i := bytes.IndexByte(child.label, sep); {
+		case i == 0:
+			// Already returned as descendants of n.
+		case i > 0:
+			segment := make([]byte, 0, len(rest)+i)
+			segment = append(append(segment, rest...), child.label[:i]...)
+			*segments = append(*segments, PathSegment{Segment: segment, HasDescendants: true})
+		default:
+			child.pathSegments(append(rest, child.label...), sep, segments)
+		}
+	}
+}
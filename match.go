@@ -0,0 +1,241 @@
+package radixtree
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrBadPattern is returned from Match when the pattern is malformed.
+var ErrBadPattern = errors.New("radixtree: syntax error in pattern")
+
+// MatchOptions is the options for Match.
+type MatchOptions struct {
+	// StopAtSeparator makes * and ? not match Separator, so a pattern
+	// like "svc/*/db" matches only one segment of paths for *.
+	StopAtSeparator bool
+	Separator       byte
+}
+
+// Match calls fn for each key which matches the pattern and its value
+// in lexicographic order of keys, like Walk. Pass nil to opts for
+// default options. The pattern syntax is:
+//
+//	pattern:
+//		{ term }
+//	term:
+//		'*'         matches any sequence of bytes
+//		'?'         matches any single byte
+//		'[' [ '!' | '^' ] { range } ']'
+//		            matches a byte in (or not in with '!' or '^') ranges,
+//		            which must not be empty
+//		c           matches byte c (c != '*', '?', '\\', '[')
+//		'\\' c      matches byte c
+//	range:
+//		c           matches byte c (c != '\\', ']')
+//		'\\' c      matches byte c
+//		lo '-' hi   matches byte c for lo <= c <= hi
+//
+// The pattern is evaluated against labels while walking the radix tree,
+// and subtrees whose keys cannot match the pattern are skipped. Match
+// returns an error wrapping ErrBadPattern if the pattern is malformed.
+func (t *Tree) Match(pattern []byte, opts *MatchOptions, fn func(key []byte, value interface{}) bool) error {
+	tokens, err := parseGlob(pattern)
+	if err != nil {
+		return err
+	}
+	m := globMatcher{tokens: tokens, fn: fn}
+	if opts != nil {
+		m.opts = *opts
+	}
+	width := len(tokens) + 1
+	m.states = make([]bool, width, width*16)
+	m.states[0] = true
+	m.closure(m.states)
+	m.match(&t.root)
+	return nil
+}
+
+type globTokenKind int
+
+const (
+	globLiteral globTokenKind = iota
+	globAny
+	globStar
+	globClass
+)
+
+type globToken struct {
+	kind globTokenKind
+	c    byte
+	// ranges holds pairs of the lowest and highest bytes for globClass.
+	ranges []byte
+	negate bool
+}
+
+func parseGlob(pattern []byte) ([]globToken, error) {
+	var tokens []globToken
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if len(tokens) == 0 || tokens[len(tokens)-1].kind != globStar {
+				tokens = append(tokens, globToken{kind: globStar})
+			}
+		case '?':
+			tokens = append(tokens, globToken{kind: globAny})
+		case '\\':
+			i++
+			if i == len(pattern) {
+				return nil, fmt.Errorf("%w: trailing backslash", ErrBadPattern)
+			}
+			tokens = append(tokens, globToken{kind: globLiteral, c: pattern[i]})
+		case '[':
+			tok, n, err := parseGlobClass(pattern[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, tok)
+			i += n
+		default:
+			tokens = append(tokens, globToken{kind: globLiteral, c: c})
+		}
+	}
+	return tokens, nil
+}
+
+// parseGlobClass parses a character class after '[' and returns the
+// token and the number of bytes consumed including ']'.
+func parseGlobClass(p []byte) (tok globToken, n int, err error) {
+	tok.kind = globClass
+	i := 0
+	if i < len(p) && (p[i] == '!' || p[i] == '^') {
+		tok.negate = true
+		i++
+	}
+	classByte := func() (byte, error) {
+		if i == len(p) {
+			return 0, fmt.Errorf("%w: unterminated character class", ErrBadPattern)
+		}
+		c := p[i]
+		if c == '\\' {
+			i++
+			if i == len(p) {
+				return 0, fmt.Errorf("%w: trailing backslash", ErrBadPattern)
+			}
+			c = p[i]
+		}
+		i++
+		return c, nil
+	}
+	for {
+		if i == len(p) {
+			return tok, 0, fmt.Errorf("%w: unterminated character class", ErrBadPattern)
+		}
+		if p[i] == ']' {
+			break
+		}
+		lo, err := classByte()
+		if err != nil {
+			return tok, 0, err
+		}
+		hi := lo
+		if i+1 < len(p) && p[i] == '-' && p[i+1] != ']' {
+			i++
+			if hi, err = classByte(); err != nil {
+				return tok, 0, err
+			}
+			if hi < lo {
+				return tok, 0, fmt.Errorf("%w: bad range in character class", ErrBadPattern)
+			}
+		}
+		tok.ranges = append(tok.ranges, lo, hi)
+	}
+	if len(tok.ranges) == 0 {
+		return tok, 0, fmt.Errorf("%w: empty character class", ErrBadPattern)
+	}
+	return tok, i + 1, nil
+}
+
+type globMatcher struct {
+	tokens []globToken
+	opts   MatchOptions
+	fn     func(key []byte, value interface{}) bool
+
+	// states holds a set of positions in tokens for each byte of the
+	// current path, where the set for the i-th byte starts at
+	// i*(len(tokens)+1), and the first set is for the empty prefix.
+	// Position len(tokens) means the whole pattern is matched.
+	states []bool
+	path   []byte
+}
+
+// match walks the subtree rooted at n and returns false if fn returns
+// false.
+func (m *globMatcher) match(n *node) bool {
+	if n.hasValue() && m.states[len(m.states)-1] && !m.fn(m.path, n.value) {
+		return false
+	}
+	for _, child := range n.children {
+		pathLen, statesLen := len(m.path), len(m.states)
+		if m.appendStates(child.label) && !m.match(child) {
+			return false
+		}
+		m.path, m.states = m.path[:pathLen], m.states[:statesLen]
+	}
+	return true
+}
+
+// appendStates appends sets of positions for bytes in label to states,
+// and returns false if no keys under the label can match the pattern.
+func (m *globMatcher) appendStates(label []byte) bool {
+	width := len(m.tokens) + 1
+	for _, c := range label {
+		m.path = append(m.path, c)
+		prev := m.states[len(m.states)-width:]
+		for i := 0; i < width; i++ {
+			m.states = append(m.states, false)
+		}
+		cur := m.states[len(m.states)-width:]
+		alive := false
+		for i, tok := range m.tokens {
+			if !prev[i] || !m.matchByte(tok, c) {
+				continue
+			}
+			if tok.kind == globStar {
+				cur[i] = true
+			} else {
+				cur[i+1] = true
+			}
+			alive = true
+		}
+		if !alive {
+			return false
+		}
+		m.closure(cur)
+	}
+	return true
+}
+
+// closure adds positions after stars, which match the empty sequence.
+func (m *globMatcher) closure(states []bool) {
+	for i, tok := range m.tokens {
+		if states[i] && tok.kind == globStar {
+			states[i+1] = true
+		}
+	}
+}
+
+func (m *globMatcher) matchByte(tok globToken, c byte) bool {
+	switch tok.kind {
+	case globLiteral:
+		return c == tok.c
+	case globClass:
+		for i := 0; i < len(tok.ranges); i += 2 {
+			if tok.ranges[i] <= c && c <= tok.ranges[i+1] {
+				return !tok.negate
+			}
+		}
+		return tok.negate
+	default: // globAny, globStar
+		return !m.opts.StopAtSeparator || c != m.opts.Separator
+	}
+}
@@ -0,0 +1,26 @@
+package radixtree
+
+// Observer is the interface for receiving changes made to a radix tree,
+// for example to mirror them to metrics or an audit log.
+//
+// Methods are called synchronously after the change is made, from the
+// goroutine which called Set, Delete, DeleteSubtree or DeleteRange. key
+// and prefix must not be modified nor retained after the methods return.
+type Observer interface {
+	// OnSet is called from Set. oldValue is nil if the key did not exist.
+	OnSet(key []byte, oldValue, newValue interface{})
+
+	// OnDelete is called from Delete when the key is deleted, and from
+	// DeleteRange for each deleted key after all of them are deleted.
+	OnDelete(key []byte, oldValue interface{})
+
+	// OnDeleteSubtree is called from DeleteSubtree when keys are deleted.
+	// removed is the number of the deleted keys.
+	OnDeleteSubtree(prefix []byte, removed int)
+}
+
+// SetObserver sets the observer for the radix tree. Pass nil to remove
+// the observer.
+func (t *Tree) SetObserver(o Observer) {
+	t.observer = o
+}
@@ -0,0 +1,232 @@
+package radixtree
+
+// Min returns the least key in the radix tree and its value.
+// ok is false if the radix tree is empty.
+func (t *Tree) Min() (key []byte, value interface{}, ok bool) {
+	return t.root.min([]byte{})
+}
+
+// Max returns the greatest key in the radix tree and its value.
+// ok is false if the radix tree is empty.
+func (t *Tree) Max() (key []byte, value interface{}, ok bool) {
+	return t.root.max([]byte{})
+}
+
+// Ceiling returns the least key which is greater than or equal to the
+// specified key and its value. ok is false if there is no such key.
+func (t *Tree) Ceiling(key []byte) (ceilingKey []byte, value interface{}, ok bool) {
+	return t.successor(key, true)
+}
+
+// Higher returns the least key which is greater than the specified key
+// and its value. ok is false if there is no such key.
+func (t *Tree) Higher(key []byte) (higherKey []byte, value interface{}, ok bool) {
+	return t.successor(key, false)
+}
+
+// Floor returns the greatest key which is less than or equal to the
+// specified key and its value. ok is false if there is no such key.
+func (t *Tree) Floor(key []byte) (floorKey []byte, value interface{}, ok bool) {
+	return t.predecessor(key, true)
+}
+
+// Lower returns the greatest key which is less than the specified key
+// and its value. ok is false if there is no such key.
+func (t *Tree) Lower(key []byte) (lowerKey []byte, value interface{}, ok bool) {
+	return t.predecessor(key, false)
+}
+
+// successor returns the least key which is greater than key, or equal
+// to key if inclusive is true.
+//
+// It follows the path to key. The answer is in the first subtree after
+// the path, which is the subtree of the next sibling of the deepest node
+// on the path having one, unless the path ends in a subtree whose keys
+// are all greater than key.
+func (t *Tree) successor(key []byte, inclusive bool) ([]byte, interface{}, bool) {
+	n := &t.root
+	rest := key
+	var next *node
+	var nextDepth int
+	for len(rest) > 0 {
+		depth := len(key) - len(rest)
+		i := n.indexForPrefix(rest)
+		if i == len(n.children) {
+			break
+		}
+		child := n.children[i]
+		l := commonPrefixLength(rest, child.label)
+		if l < len(child.label) {
+			if l == len(rest) || child.label[l] > rest[l] {
+				return child.min(appendKey(key[:depth], child.label))
+			}
+			i++
+			if i < len(n.children) {
+				return n.children[i].min(appendKey(key[:depth], n.children[i].label))
+			}
+			break
+		}
+		if i+1 < len(n.children) {
+			next, nextDepth = n.children[i+1], depth
+		}
+		n = child
+		rest = rest[l:]
+	}
+	if len(rest) == 0 {
+		if inclusive && n.hasValue() {
+			return appendKey(key, nil), n.value, true
+		}
+		if len(n.children) > 0 {
+			return n.children[0].min(appendKey(key, n.children[0].label))
+		}
+	}
+	if next == nil {
+		return nil, nil, false
+	}
+	return next.min(appendKey(key[:nextDepth], next.label))
+}
+
+// predecessor returns the greatest key which is less than key, or equal
+// to key if inclusive is true.
+//
+// It follows the path to key. The answer is in the last subtree before
+// the path, or is the key of the deepest node on the path which has a
+// value. Of them, the deepest one is the greatest.
+func (t *Tree) predecessor(key []byte, inclusive bool) ([]byte, interface{}, bool) {
+	n := &t.root
+	rest := key
+	var prev *node
+	var prevDepth int
+	var prevSelf bool
+	for len(rest) > 0 {
+		depth := len(key) - len(rest)
+		i := n.indexForPrefix(rest)
+		if i > 0 {
+			prev, prevDepth, prevSelf = n.children[i-1], depth, false
+		} else if n.hasValue() {
+			prev, prevDepth, prevSelf = n, depth, true
+		}
+		if i == len(n.children) {
+			break
+		}
+		child := n.children[i]
+		l := commonPrefixLength(rest, child.label)
+		if l < len(child.label) {
+			if l < len(rest) && child.label[l] < rest[l] {
+				return child.max(appendKey(key[:depth], child.label))
+			}
+			break
+		}
+		n = child
+		rest = rest[l:]
+	}
+	if len(rest) == 0 && inclusive && n.hasValue() {
+		return appendKey(key, nil), n.value, true
+	}
+	switch {
+	case prev == nil:
+		return nil, nil, false
+	case prevSelf:
+		return appendKey(key[:prevDepth], nil), prev.value, true
+	default:
+		return prev.max(appendKey(key[:prevDepth], prev.label))
+	}
+}
+
+// appendKey returns a newly allocated slice of key followed by label.
+func appendKey(key, label []byte) []byte {
+	b := make([]byte, len(key), len(key)+len(label)+16)
+	copy(b, key)
+	return append(b, label...)
+}
+
+// min returns the least key in the subtree rooted at n and its value.
+// key must be the key of n, and is extended to build the result.
+func (n *node) min(key []byte) ([]byte, interface{}, bool) {
+	for !n.hasValue() {
+		if len(n.children) == 0 {
+			return nil, nil, false
+		}
+		n = n.children[0]
+		key = append(key, n.label...)
+	}
+	return key, n.value, true
+}
+
+// max returns the greatest key in the subtree rooted at n and its value.
+// key must be the key of n, and is extended to build the result.
+func (n *node) max(key []byte) ([]byte, interface{}, bool) {
+	for len(n.children) > 0 {
+		n = n.children[len(n.children)-1]
+		key = append(key, n.label...)
+	}
+	if !n.hasValue() {
+		return nil, nil, false
+	}
+	return key, n.value, true
+}
+
+// Select returns the i-th least key in the radix tree and its value,
+// counting from 0. ok is false if i is out of range.
+//
+// Each node keeps the number of keys in its subtree, so Select skips
+// subtrees before the i-th key without visiting them. It still adds up
+// the counts of children before the path one by one, so it costs the
+// depth of the key times the number of children at each level, which
+// is at most 256.
+func (t *Tree) Select(i int) (key []byte, value interface{}, ok bool) {
+	n := &t.root
+	if i < 0 || i >= n.count {
+		return nil, nil, false
+	}
+	key = []byte{}
+	for {
+		if n.hasValue() {
+			if i == 0 {
+				return key, n.value, true
+			}
+			i--
+		}
+		for _, child := range n.children {
+			if i < child.count {
+				n = child
+				break
+			}
+			i -= child.count
+		}
+		key = append(key, n.label...)
+	}
+}
+
+// Rank returns the number of keys in the radix tree which are less than
+// the specified key. If the key exists, it is the index of the key for
+// Select. Like Select, it costs the depth of the key times the number of
+// children at each level.
+func (t *Tree) Rank(key []byte) int {
+	n := &t.root
+	rest := key
+	rank := 0
+	for len(rest) > 0 {
+		if n.hasValue() {
+			rank++
+		}
+		i := n.indexForPrefix(rest)
+		for _, child := range n.children[:i] {
+			rank += child.count
+		}
+		if i == len(n.children) {
+			break
+		}
+		child := n.children[i]
+		l := commonPrefixLength(rest, child.label)
+		if l < len(child.label) {
+			if l < len(rest) && child.label[l] < rest[l] {
+				rank += child.count
+			}
+			break
+		}
+		n = child
+		rest = rest[l:]
+	}
+	return rank
+}
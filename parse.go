@@ -0,0 +1,147 @@
+package radixtree
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseError is the error returned from ParseASCII for malformed input.
+type ParseError struct {
+	// Line and Column are the position of the error, counting from 1.
+	// Column counts bytes.
+	Line, Column int
+	Err          error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("radixtree: parse error at line %d, column %d: %v", e.Line, e.Column, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseASCII parses the ASCII art representation of a radix tree in
+// the layout of String and returns the tree with the same structure.
+//
+// Each node line may be followed by a space and the text of the value,
+// which is passed to valueParser to get the value. If valueParser is nil,
+// the text is used as a string value. Note String prints the type of
+// values after them, so valueParser must handle it for the output of String.
+//
+// ParseASCII returns a *ParseError for malformed input and for
+// structures which Set, Delete and DeleteSubtree would not make.
+func ParseASCII(s string, valueParser func(string) (interface{}, error)) (*Tree, error) {
+	p := asciiParser{valueParser: valueParser}
+	return p.parse(s)
+}
+
+type asciiParser struct {
+	valueParser func(string) (interface{}, error)
+}
+
+type parsedNode struct {
+	n    *node
+	line int
+}
+
+func (p *asciiParser) parse(s string) (*Tree, error) {
+	if s == "" {
+		return nil, &ParseError{Line: 1, Column: 1, Err: errors.New("empty input")}
+	}
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+
+	t := New()
+	if !strings.HasPrefix(lines[0], ".") {
+		return nil, &ParseError{Line: 1, Column: 1, Err: errors.New(`root line must start with "."`)}
+	}
+	if err := p.parseValue(&t.root, lines[0][1:], 1, 2); err != nil {
+		return nil, err
+	}
+
+	// path holds the last node at each depth, and isLast holds whether
+	// the node at each depth was printed as the last child.
+	path := []*node{&t.root}
+	isLast := []bool{true}
+	nodes := []parsedNode{{n: &t.root, line: 1}}
+	for i, line := range lines[1:] {
+		lineNo := i + 2
+		col := 0
+		depth := 1
+		for ; depth < len(path) && len(line) >= col+3; col += 3 {
+			want := "|  "
+			if isLast[depth] {
+				want = "   "
+			}
+			if line[col:col+3] != want {
+				break
+			}
+			depth++
+		}
+		var last bool
+		switch {
+		case strings.HasPrefix(line[col:], "|-- "):
+		case strings.HasPrefix(line[col:], "`-- "):
+			last = true
+		default:
+			return nil, &ParseError{Line: lineNo, Column: col + 1, Err: errors.New("unexpected indentation or line drawing")}
+		}
+		if depth < len(path) && isLast[depth] {
+			return nil, &ParseError{Line: lineNo, Column: col + 1, Err: errors.New("node after the last child")}
+		}
+		col += 4
+
+		quoted, err := strconv.QuotedPrefix(line[col:])
+		if err != nil {
+			return nil, &ParseError{Line: lineNo, Column: col + 1, Err: errors.New("expected quoted label")}
+		}
+		label, err := strconv.Unquote(quoted)
+		if err != nil {
+			return nil, &ParseError{Line: lineNo, Column: col + 1, Err: err}
+		}
+		n := &node{label: []byte(label), value: noValue}
+		col += len(quoted)
+		if err := p.parseValue(n, line[col:], lineNo, col+1); err != nil {
+			return nil, err
+		}
+
+		parent := path[depth-1]
+		parent.children = append(parent.children, n)
+		path = append(path[:depth], n)
+		isLast = append(isLast[:depth], last)
+		nodes = append(nodes, parsedNode{n: n, line: lineNo})
+	}
+
+	for i, pn := range nodes {
+		if err := pn.n.validate(pn.n.label, i == 0); err != nil {
+			return nil, &ParseError{Line: pn.line, Column: 1, Err: err}
+		}
+	}
+	t.root.updateCount()
+	return t, nil
+}
+
+// parseValue parses the rest of a node line after the label.
+// col is the column of rest.
+func (p *asciiParser) parseValue(n *node, rest string, line, col int) error {
+	if rest == "" {
+		return nil
+	}
+	if rest[0] != ' ' {
+		return &ParseError{Line: line, Column: col, Err: errors.New("expected space before value")}
+	}
+	text := rest[1:]
+	if p.valueParser == nil {
+		n.value = text
+		return nil
+	}
+	v, err := p.valueParser(text)
+	if err != nil {
+		return &ParseError{Line: line, Column: col + 1, Err: err}
+	}
+	n.value = v
+	return nil
+}
@@ -15,7 +15,6 @@ package radixtree
 
 import (
 	"bytes"
-	"fmt"
 	"sort"
 	"strconv"
 )
@@ -23,6 +22,19 @@ import (
 // Tree is a radix tree.
 type Tree struct {
 	root node
+
+	// version is incremented on each change to detect changes
+	// during iterations.
+	version uint64
+
+	codec    ValueCodec
+	observer Observer
+	watchers *Tree
+
+	// scorer is the Scorer whose scores are cached in nodes, and
+	// scoreEpoch is incremented when it is changed.
+	scorer     *Scorer
+	scoreEpoch uint64
 }
 
 type node struct {
@@ -30,6 +42,15 @@ type node struct {
 	value interface{}
 
 	children []*node
+
+	// count is the number of values in the subtree rooted at the node,
+	// including the value of the node itself.
+	count int
+
+	// maxScore is the maximum score in the subtree for TopK, which is
+	// valid if scoreEpoch is equal to the one of the tree.
+	maxScore   float64
+	scoreEpoch uint64
 }
 
 var noValue = &struct{}{}
@@ -44,43 +65,11 @@ func New() *Tree {
 }
 
 // String returns the ASCII art representation of the radix tree.
+// It is same as the output of Format with the zero FormatOptions.
 func (t *Tree) String() string {
-	var buf []byte
-	buf = append(buf, '.')
-	if t.root.hasValue() {
-		buf = append(buf, fmt.Sprintf(" %+v %T", t.root.value, t.root.value)...)
-	}
-	buf = append(buf, '\n')
-
-	var doPrint func(p *node, leading []byte)
-	doPrint = func(p *node, leading []byte) {
-		for i := range p.children {
-			n := p.children[i]
-			buf = append(buf, leading...)
-			if i < len(p.children)-1 {
-				buf = append(buf, '|')
-			} else {
-				buf = append(buf, '`')
-			}
-			buf = append(buf, "-- "...)
-			buf = strconv.AppendQuote(buf, string(n.label))
-			if n.hasValue() {
-				buf = append(buf, fmt.Sprintf(" %+v %T", n.value, n.value)...)
-			}
-			buf = append(buf, '\n')
-			if len(n.children) > 0 {
-				var leading2 []byte
-				if i < len(p.children)-1 {
-					leading2 = append(leading, "|  "...)
-				} else {
-					leading2 = append(leading, "   "...)
-				}
-				doPrint(n, leading2)
-			}
-		}
-	}
-	doPrint(&t.root, nil)
-	return string(buf)
+	var f formatter
+	f.format(&t.root, []byte{'.'})
+	return string(f.buf)
 }
 
 // Get returns the value for the key.
@@ -95,6 +84,9 @@ func (t *Tree) Get(key []byte) (value interface{}, exists bool) {
 		prefix = prefix[len(n.children[i].label):]
 		n = n.children[i]
 	}
+	if !n.hasValue() {
+		return nil, false
+	}
 	return n.value, true
 }
 
@@ -104,17 +96,42 @@ func (t *Tree) Get(key []byte) (value interface{}, exists bool) {
 // You are free to modify the backing store of the key after
 // calling Set.
 func (t *Tree) Set(key []byte, value interface{}) {
-	n := &t.root
+	old := t.set(key, value)
+	t.version++
+	if t.observer != nil {
+		t.observer.OnSet(key, old, value)
+	}
+	t.notify(OpSet, key, nil)
+}
+
+// set sets the value for the key and returns the old value, or nil if
+// the key did not exist.
+func (t *Tree) set(key []byte, value interface{}) (old interface{}) {
+	old, added := t.root.set(key, value)
+	delta := 0
+	if added {
+		delta = 1
+	}
+	t.root.updatePath(key, delta)
+	return old
+}
+
+// set sets the value for the key in the subtree rooted at n, where key
+// is relative to the key of n. It returns whether the key is added.
+// The caller must update nodes on the path to the key with updatePath.
+func (n *node) set(key []byte, value interface{}) (old interface{}, added bool) {
 	if len(key) == 0 {
+		old = n.valueOrNil()
+		added = !n.hasValue()
 		n.value = value
-		return
+		return old, added
 	}
 	prefix := key
 	for len(prefix) > 0 {
 		i := n.indexForPrefix(prefix)
 		if i == len(n.children) {
 			n.children = append(n.children, newNode(prefix, value, nil))
-			return
+			return nil, true
 		}
 		child := n.children[i]
 		childLabel := child.label
@@ -124,7 +141,7 @@ func (t *Tree) Set(key []byte, value interface{}) {
 			n.children = append(n.children, nil)
 			copy(n.children[i+1:], n.children[i:])
 			n.children[i] = newNode(prefix, value, nil)
-			return
+			return nil, true
 		}
 		if l < len(prefix) {
 			if l < len(childLabel) {
@@ -137,20 +154,26 @@ func (t *Tree) Set(key []byte, value interface{}) {
 					children = []*node{child, newNode(myRestLabel, value, nil)}
 				}
 				n.children[i] = newNode(prefix[:l], noValue, children)
-				return
+				n.children[i].count = child.count
+				return nil, true
 			}
 		} else { // l == len(prefix)
 			if l < len(childLabel) {
 				child.label = childLabel[l:]
 				n.children[i] = newNode(prefix, value, []*node{child})
-			} else { // l == len(childLabel)
-				n.children[i].value = value
+				n.children[i].count = child.count
+				return nil, true
 			}
-			return
+			// l == len(childLabel)
+			old = child.valueOrNil()
+			added = !child.hasValue()
+			child.value = value
+			return old, added
 		}
 		prefix = prefix[len(childLabel):]
 		n = child
 	}
+	return nil, false
 }
 
 // newNode creates a new node. The label will copied to a newly allocated
@@ -177,6 +200,29 @@ func newNode(label []byte, value interface{}, children []*node) *node {
 
 // Delete deletes the specified key in the radix tree.
 func (t *Tree) Delete(key []byte) (deleted bool) {
+	old, deleted := t.delete(key)
+	if deleted {
+		t.version++
+		if t.observer != nil {
+			t.observer.OnDelete(key, old)
+		}
+		t.notify(OpDelete, key, nil)
+	}
+	return deleted
+}
+
+// delete deletes the key and returns the old value.
+func (t *Tree) delete(key []byte) (old interface{}, deleted bool) {
+	if len(key) == 0 {
+		if !t.root.hasValue() {
+			return nil, false
+		}
+		old = t.root.value
+		t.root.value = noValue
+		t.root.updatePath(nil, -1)
+		return old, true
+	}
+
 	parent := &t.root
 	prefix := key
 	var n *node
@@ -184,20 +230,26 @@ func (t *Tree) Delete(key []byte) (deleted bool) {
 	for len(prefix) > 0 {
 		i = parent.indexForPrefix(prefix)
 		if i == len(parent.children) {
-			return false
+			return nil, false
 		}
 		n = parent.children[i]
 		l := commonPrefixLength(prefix, n.label)
-		if l == 0 {
-			return false
+		if l < len(n.label) {
+			return nil, false
 		}
 		if l == len(prefix) {
 			break
 		}
-		prefix = prefix[len(n.label):]
+		prefix = prefix[l:]
 		parent = n
 	}
 
+	if !n.hasValue() {
+		return nil, false
+	}
+	old = n.value
+	t.root.updatePath(key, -1)
+
 	childCount := len(n.children)
 	switch childCount {
 	case 0:
@@ -217,6 +269,7 @@ func (t *Tree) Delete(key []byte) (deleted bool) {
 					label:    append(parent.label, sibling.label...),
 					value:    sibling.value,
 					children: sibling.children,
+					count:    sibling.count,
 				}
 			}
 		}
@@ -226,19 +279,46 @@ func (t *Tree) Delete(key []byte) (deleted bool) {
 			label:    append(n.label, child.label...),
 			value:    child.value,
 			children: child.children,
+			count:    child.count,
 		}
 	default: // childCount > 1
-		if !n.hasValue() {
-			return false
-		}
 		n.value = noValue
 	}
-	return true
+	return old, true
 }
 
 // DeleteSubtree deletes a subtree which has the specified prefix
-// in the radix tree.
+// in the radix tree. Passing nil or an empty byte slice to prefix
+// deletes all keys in the radix tree.
 func (t *Tree) DeleteSubtree(prefix []byte) (deleted bool) {
+	var removed int
+	if t.observer != nil {
+		removed = t.CountPrefix(prefix)
+	}
+	nested := t.nestedWatchers(prefix)
+	deleted = t.deleteSubtree(prefix)
+	if deleted {
+		t.version++
+		if t.observer != nil {
+			t.observer.OnDeleteSubtree(prefix, removed)
+		}
+		t.notify(OpDeleteSubtree, prefix, nested)
+	}
+	return deleted
+}
+
+func (t *Tree) deleteSubtree(prefix []byte) (deleted bool) {
+	if len(prefix) == 0 {
+		if !t.root.hasValue() && len(t.root.children) == 0 {
+			return false
+		}
+		t.root.value = noValue
+		t.root.children = nil
+		t.root.updatePath(nil, -t.root.count)
+		return true
+	}
+
+	key := prefix
 	parent := &t.root
 	var n *node
 	var i, l int
@@ -249,15 +329,18 @@ func (t *Tree) DeleteSubtree(prefix []byte) (deleted bool) {
 		}
 		n = parent.children[i]
 		l = commonPrefixLength(prefix, n.label)
-		if l == 0 {
-			return false
-		}
 		if l == len(prefix) {
 			break
 		}
-		prefix = prefix[len(n.label):]
+		if l < len(n.label) {
+			return false
+		}
+		prefix = prefix[l:]
 		parent = n
 	}
+	// Nodes on the path to n are the ones whose keys are prefixes of
+	// the key of parent, so they are adjusted by walking the path once.
+	t.root.updatePath(key[:len(key)-len(prefix)], -n.count)
 
 	parentChildCount := len(parent.children)
 	if parent.hasValue() || parent == &t.root {
@@ -275,6 +358,7 @@ func (t *Tree) DeleteSubtree(prefix []byte) (deleted bool) {
 				label:    append(parent.label, sibling.label...),
 				value:    sibling.value,
 				children: sibling.children,
+				count:    sibling.count,
 			}
 		} else {
 			parent.children = nil
@@ -283,10 +367,263 @@ func (t *Tree) DeleteSubtree(prefix []byte) (deleted bool) {
 	return true
 }
 
+// DeleteRange deletes keys in the interval [start, end) in the radix
+// tree and returns the number of deleted keys. A nil start or end means
+// the interval is not bounded on that side.
+//
+// Children whose subtrees are entirely in the interval are dropped at
+// once, and only nodes on the paths to start and end are visited other
+// than those. The observer and watchers are notified of each deleted
+// key as Delete does.
+func (t *Tree) DeleteRange(start, end []byte) (removed int) {
+	d := rangeDeleter{start: start, end: end}
+	if t.observer != nil || t.watchers != nil {
+		d.collect = true
+		d.key = make([]byte, 0, 64)
+	}
+	d.delete(&t.root, 0, start != nil, end != nil)
+	if d.removed == 0 {
+		return 0
+	}
+	t.version++
+	for i, key := range d.keys {
+		if t.observer != nil {
+			t.observer.OnDelete(key, d.values[i])
+		}
+		t.notify(OpDelete, key, nil)
+	}
+	return d.removed
+}
+
+type rangeDeleter struct {
+	start, end []byte
+	removed    int
+
+	// collect tells whether deleted keys and values are collected
+	// to keys and values. key is the key of the current node then.
+	collect bool
+	key     []byte
+	keys    [][]byte
+	values  []interface{}
+}
+
+// delete deletes keys in the range in the subtree rooted at n except
+// that n itself is not merged nor removed, which is done by the caller.
+// keyLen is the length of the key of n, and lower and upper are the
+// ones for walk of rangeWalker.
+func (d *rangeDeleter) delete(n *node, keyLen int, lower, upper bool) {
+	removed := d.removed
+	if n.hasValue() && !(lower && keyLen < len(d.start)) && !(upper && keyLen == len(d.end)) {
+		d.deleted(d.key, n.value)
+		n.value = noValue
+	}
+
+	lo, hi := n.childrenInRange(keyLen, d.start, d.end, lower, upper)
+	// Keep children which are out of the range or still have keys
+	// after deletion, moving them to children[lo:w].
+	w := lo
+	for i := lo; i < hi; i++ {
+		child := n.children[i]
+		childLower, childUpper := false, false
+		skip := false
+		if lower && i == lo && keyLen < len(d.start) {
+			childLower, skip = boundState(child.label, d.start[keyLen:], -1)
+		}
+		if !skip && upper && i == hi-1 {
+			childUpper, skip = boundState(child.label, d.end[keyLen:], 1)
+		}
+		switch {
+		case skip:
+			n.children[w] = child
+			w++
+		case !childLower && !childUpper:
+			d.deletedSubtree(child)
+		default:
+			if d.collect {
+				d.key = append(d.key, child.label...)
+			}
+			d.delete(child, keyLen+len(child.label), childLower, childUpper)
+			if d.collect {
+				d.key = d.key[:len(d.key)-len(child.label)]
+			}
+			switch {
+			case child.hasValue() || len(child.children) > 1:
+				n.children[w] = child
+				w++
+			case len(child.children) == 1:
+				grandChild := child.children[0]
+				n.children[w] = &node{
+					label:    append(child.label, grandChild.label...),
+					value:    grandChild.value,
+					children: grandChild.children,
+					count:    grandChild.count,
+				}
+				w++
+			}
+		}
+	}
+	if w < hi {
+		n.children = append(n.children[:w], n.children[hi:]...)
+		if len(n.children) == 0 {
+			n.children = nil
+		}
+	}
+	if d.removed > removed {
+		n.count -= d.removed - removed
+		n.scoreEpoch = 0
+	}
+}
+
+// deletedSubtree records the deletion of all keys in the subtree of child.
+func (d *rangeDeleter) deletedSubtree(child *node) {
+	if !d.collect {
+		d.removed += child.count
+		return
+	}
+	child.walk(append(d.key, child.label...), func(key []byte, value interface{}) bool {
+		d.deleted(key, value)
+		return true
+	})
+}
+
+func (d *rangeDeleter) deleted(key []byte, value interface{}) {
+	d.removed++
+	if d.collect {
+		d.keys = append(d.keys, append([]byte{}, key...))
+		d.values = append(d.values, value)
+	}
+}
+
+// replaceRoot replaces all nodes in the radix tree with the ones under root.
+func (t *Tree) replaceRoot(root *node) {
+	root.updateCount()
+	t.root = *root
+	t.version++
+}
+
+// updateCount sets count of all nodes in the subtree rooted at n and
+// returns the count of n. It is used for nodes built without Set.
+func (n *node) updateCount() int {
+	n.count = 0
+	if n.hasValue() {
+		n.count = 1
+	}
+	for _, child := range n.children {
+		n.count += child.updateCount()
+	}
+	return n.count
+}
+
+// updatePath adds delta to count of n and its descendants on the path
+// to the key, which is relative to the key of n, and invalidates scores
+// cached in them for TopK. The path stops at the deepest node whose key
+// is a prefix of the key.
+func (n *node) updatePath(key []byte, delta int) {
+	for {
+		n.count += delta
+		n.scoreEpoch = 0
+		if len(key) == 0 {
+			return
+		}
+		i := n.indexForPrefix(key)
+		if i == len(n.children) || !bytes.HasPrefix(key, n.children[i].label) {
+			return
+		}
+		key = key[len(n.children[i].label):]
+		n = n.children[i]
+	}
+}
+
 func (n *node) hasValue() bool {
 	return n.value != noValue
 }
 
+func (n *node) valueOrNil() interface{} {
+	if !n.hasValue() {
+		return nil
+	}
+	return n.value
+}
+
+// CountPrefix returns the number of keys which have the specified
+// prefix in the radix tree. Passing nil or an empty byte slice to prefix
+// returns the number of all keys.
+//
+// Each node keeps the number of keys in its subtree, so CountPrefix only
+// follows the path to the prefix and does not walk the subtree.
+func (t *Tree) CountPrefix(prefix []byte) int {
+	n, _ := t.subtree(prefix)
+	if n == nil {
+		return 0
+	}
+	return n.count
+}
+
+// walk calls fn for each value in the subtree rooted at n in the
+// lexicographic order of keys. key must be the key of n and its backing
+// store is reused to build keys of descendants, so fn must not retain
+// the key passed to it. walk stops and returns false when fn returns false.
+func (n *node) walk(key []byte, fn func(key []byte, value interface{}) bool) bool {
+	if n.hasValue() && !fn(key, n.value) {
+		return false
+	}
+	for _, child := range n.children {
+		if !child.walk(append(key, child.label...), fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// walkPath calls fn for the value of each node whose key is a prefix
+// of key, from the root to the deepest one.
+func (t *Tree) walkPath(key []byte, fn func(value interface{})) {
+	n := &t.root
+	prefix := key
+	for {
+		if n.hasValue() {
+			fn(n.value)
+		}
+		if len(prefix) == 0 {
+			return
+		}
+		i := n.indexForPrefix(prefix)
+		if i == len(n.children) || !bytes.HasPrefix(prefix, n.children[i].label) {
+			return
+		}
+		prefix = prefix[len(n.children[i].label):]
+		n = n.children[i]
+	}
+}
+
+// subtree returns the topmost node whose key has the specified prefix
+// and the key of the node. It returns a nil node if no key in the radix
+// tree has the prefix.
+func (t *Tree) subtree(prefix []byte) (n *node, key []byte) {
+	n = &t.root
+	rest := prefix
+	for len(rest) > 0 {
+		i := n.indexForPrefix(rest)
+		if i == len(n.children) {
+			return nil, nil
+		}
+		child := n.children[i]
+		l := commonPrefixLength(rest, child.label)
+		if l == len(rest) {
+			consumed := len(prefix) - len(rest)
+			key = make([]byte, consumed, consumed+len(child.label))
+			copy(key, prefix)
+			return child, append(key, child.label...)
+		}
+		if l < len(child.label) {
+			return nil, nil
+		}
+		rest = rest[l:]
+		n = child
+	}
+	return n, []byte{}
+}
+
 func (n *node) indexForPrefix(prefix []byte) int {
 	f := func(i int) bool {
 		label := n.children[i].label
@@ -299,42 +636,9 @@ func (n *node) indexForPrefix(prefix []byte) int {
 }
 
 func (n *node) String() string {
-	var buf []byte
-	buf = strconv.AppendQuote(buf, string(n.label))
-	if n.hasValue() {
-		buf = append(buf, fmt.Sprintf(" %+v %T", n.value, n.value)...)
-	}
-	buf = append(buf, '\n')
-
-	var doPrint func(p *node, leading []byte)
-	doPrint = func(p *node, leading []byte) {
-		for i := range p.children {
-			n := p.children[i]
-			buf = append(buf, leading...)
-			if i < len(p.children)-1 {
-				buf = append(buf, '|')
-			} else {
-				buf = append(buf, '`')
-			}
-			buf = append(buf, "-- "...)
-			buf = strconv.AppendQuote(buf, string(n.label))
-			if n.hasValue() {
-				buf = append(buf, fmt.Sprintf(" %+v %T", n.value, n.value)...)
-			}
-			buf = append(buf, '\n')
-			if len(n.children) > 0 {
-				var leading2 []byte
-				if i < len(p.children)-1 {
-					leading2 = append(leading, "|  "...)
-				} else {
-					leading2 = append(leading, "   "...)
-				}
-				doPrint(n, leading2)
-			}
-		}
-	}
-	doPrint(n, nil)
-	return string(buf)
+	var f formatter
+	f.format(n, strconv.AppendQuote(nil, string(n.label)))
+	return string(f.buf)
 }
 
 func commonPrefixLength(a, b []byte) int {
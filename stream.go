@@ -0,0 +1,236 @@
+package radixtree
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// streamMagic and streamVersion are put at the beginning of the output
+// of WriteTo.
+//
+// The format after them is a sequence of sections. Each section is
+// encoded as follows:
+//
+//	payload length (uint32, little endian)
+//	payload
+//	CRC-32C of the payload (uint32, little endian)
+//
+// The concatenation of payloads is the nodes in the same format as
+// MarshalBinary. The last section has an empty payload and no checksum.
+const (
+	streamMagic   = "RDXS"
+	streamVersion = 1
+)
+
+// streamSectionSize is the maximum payload length of a section written
+// by WriteTo.
+const streamSectionSize = 64 * 1024
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// WriteTo implements the io.WriterTo interface. It writes the radix
+// tree to w in a streaming format which is read by ReadFrom.
+// Nodes are encoded in depth-first order and written in checksummed
+// sections, so the whole encoded tree is never held in memory.
+// Values are encoded with the codec set with SetValueCodec.
+func (t *Tree) WriteTo(w io.Writer) (n int64, err error) {
+	sw := &sectionWriter{
+		w:   w,
+		buf: make([]byte, 0, streamSectionSize),
+	}
+	m, err := w.Write(append([]byte(streamMagic), streamVersion))
+	sw.n = int64(m)
+	if err != nil {
+		return sw.n, err
+	}
+	if err := sw.writeNode(&t.root, t.valueCodec()); err != nil {
+		return sw.n, err
+	}
+	if err := sw.flush(true); err != nil {
+		return sw.n, err
+	}
+	return sw.n, nil
+}
+
+type sectionWriter struct {
+	w   io.Writer
+	buf []byte
+	n   int64
+}
+
+func (sw *sectionWriter) writeNode(n *node, codec ValueCodec) error {
+	var err error
+	sw.buf, err = n.appendBinaryHeader(sw.buf, codec)
+	if err != nil {
+		return err
+	}
+	if len(sw.buf) >= streamSectionSize {
+		if err := sw.flush(false); err != nil {
+			return err
+		}
+	}
+	for _, child := range n.children {
+		if err := sw.writeNode(child, codec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flush writes full sections in the buffer. If last is true, it writes
+// all the buffered data and the terminating empty section.
+func (sw *sectionWriter) flush(last bool) error {
+	var hdr [4]byte
+	for len(sw.buf) >= streamSectionSize || (last && len(sw.buf) > 0) {
+		payload := sw.buf
+		if len(payload) > streamSectionSize {
+			payload = payload[:streamSectionSize]
+		}
+		binary.LittleEndian.PutUint32(hdr[:], uint32(len(payload)))
+		if err := sw.write(hdr[:]); err != nil {
+			return err
+		}
+		if err := sw.write(payload); err != nil {
+			return err
+		}
+		binary.LittleEndian.PutUint32(hdr[:], crc32.Checksum(payload, crc32cTable))
+		if err := sw.write(hdr[:]); err != nil {
+			return err
+		}
+		sw.buf = sw.buf[:copy(sw.buf, sw.buf[len(payload):])]
+	}
+	if last {
+		binary.LittleEndian.PutUint32(hdr[:], 0)
+		return sw.write(hdr[:])
+	}
+	return nil
+}
+
+func (sw *sectionWriter) write(p []byte) error {
+	m, err := sw.w.Write(p)
+	sw.n += int64(m)
+	return err
+}
+
+// ErrChecksum is returned from ReadFrom when a section of the input
+// has a wrong checksum.
+var ErrChecksum = errors.New("radixtree: checksum mismatch")
+
+// ReadFrom implements the io.ReaderFrom interface. It replaces all keys
+// in the radix tree with the ones read from r, which must be written by
+// WriteTo. ReadFrom reads exactly up to the end of the encoded tree.
+// Values are decoded with the codec set with SetValueCodec.
+//
+// The checksum of each section is verified before decoding nodes in it.
+// ReadFrom returns an error wrapping io.ErrUnexpectedEOF for truncated
+// input, ErrChecksum for a corrupted section and ErrInvalidBinary for
+// malformed nodes. The radix tree is not modified on errors.
+func (t *Tree) ReadFrom(r io.Reader) (n int64, err error) {
+	sr := &sectionReader{r: r}
+	var hdr [len(streamMagic) + 1]byte
+	if err := sr.readFull(hdr[:], "header"); err != nil {
+		return sr.n, err
+	}
+	if string(hdr[:len(streamMagic)]) != streamMagic {
+		return sr.n, fmt.Errorf("%w: bad magic", ErrInvalidBinary)
+	}
+	if v := hdr[len(streamMagic)]; v != streamVersion {
+		return sr.n, fmt.Errorf("%w: unsupported version %d", ErrInvalidBinary, v)
+	}
+
+	d := binaryDecoder{r: sr, codec: t.valueCodec()}
+	root, err := d.decodeNode(true)
+	if err != nil {
+		return sr.n, err
+	}
+	if _, err := sr.ReadByte(); err != io.EOF {
+		if err == nil {
+			err = fmt.Errorf("%w: trailing data in section %d", ErrInvalidBinary, sr.section)
+		}
+		return sr.n, err
+	}
+	t.replaceRoot(root)
+	return sr.n, nil
+}
+
+// sectionReader reads payloads of sections, verifying their checksums.
+// It returns io.EOF at the terminating empty section.
+type sectionReader struct {
+	r       io.Reader
+	n       int64
+	payload []byte
+	off     int
+	section int
+	done    bool
+}
+
+func (sr *sectionReader) readFull(p []byte, what string) error {
+	m, err := io.ReadFull(sr.r, p)
+	sr.n += int64(m)
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("radixtree: truncated stream at %s: %w", what, io.ErrUnexpectedEOF)
+		}
+		return err
+	}
+	return nil
+}
+
+func (sr *sectionReader) next() error {
+	if sr.done {
+		return io.EOF
+	}
+	sr.section++
+	var hdr [4]byte
+	if err := sr.readFull(hdr[:], fmt.Sprintf("length of section %d", sr.section)); err != nil {
+		return err
+	}
+	l := binary.LittleEndian.Uint32(hdr[:])
+	if l == 0 {
+		sr.done = true
+		return io.EOF
+	}
+	if l > streamSectionSize {
+		return fmt.Errorf("%w: section %d too large: %d bytes", ErrInvalidBinary, sr.section, l)
+	}
+	if cap(sr.payload) < int(l) {
+		sr.payload = make([]byte, l)
+	}
+	sr.payload = sr.payload[:l]
+	sr.off = 0
+	if err := sr.readFull(sr.payload, fmt.Sprintf("payload of section %d", sr.section)); err != nil {
+		return err
+	}
+	if err := sr.readFull(hdr[:], fmt.Sprintf("checksum of section %d", sr.section)); err != nil {
+		return err
+	}
+	if crc32.Checksum(sr.payload, crc32cTable) != binary.LittleEndian.Uint32(hdr[:]) {
+		return fmt.Errorf("%w in section %d", ErrChecksum, sr.section)
+	}
+	return nil
+}
+
+func (sr *sectionReader) ReadByte() (byte, error) {
+	for sr.off == len(sr.payload) {
+		if err := sr.next(); err != nil {
+			return 0, err
+		}
+	}
+	c := sr.payload[sr.off]
+	sr.off++
+	return c, nil
+}
+
+func (sr *sectionReader) Read(p []byte) (int, error) {
+	for sr.off == len(sr.payload) {
+		if err := sr.next(); err != nil {
+			return 0, err
+		}
+	}
+	m := copy(p, sr.payload[sr.off:])
+	sr.off += m
+	return m, nil
+}
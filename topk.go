@@ -0,0 +1,135 @@
+package radixtree
+
+import (
+	"bytes"
+	"container/heap"
+	"math"
+)
+
+// Scorer computes scores of keys for TopK.
+//
+// The maximum score in each subtree is cached in nodes of the radix tree
+// for the Scorer last passed to TopK. The cache is invalidated on nodes
+// on the path to a changed key, so the scoring function must return the
+// same score for the same key and value.
+type Scorer struct {
+	fn func(key []byte, value interface{}) float64
+}
+
+// NewScorer returns a new Scorer which computes scores with fn. The key
+// passed to fn is only valid until fn returns. Keys with NaN or negative
+// infinity scores are never returned from TopK.
+func NewScorer(fn func(key []byte, value interface{}) float64) *Scorer {
+	return &Scorer{fn: fn}
+}
+
+// Completion is a key returned from TopK.
+type Completion struct {
+	Key   []byte
+	Value interface{}
+	Score float64
+}
+
+// TopK returns at most k keys which have the specified prefix with the
+// highest scores computed by scorer, in descending order of scores.
+// Keys with the same score are returned in lexicographic order.
+//
+// TopK searches nodes in descending order of the maximum score in their
+// subtrees, so it visits only nodes on the paths to the returned keys
+// and their children once the maximum scores are cached. Computing them
+// for the first time after scorer is changed walks the subtree.
+func (t *Tree) TopK(prefix []byte, k int, scorer *Scorer) []Completion {
+	n, key := t.subtree(prefix)
+	if n == nil || k <= 0 {
+		return nil
+	}
+	if t.scorer != scorer {
+		t.scorer = scorer
+		t.scoreEpoch++
+	}
+
+	var results []Completion
+	h := &completionHeap{}
+	if score := t.maxScore(n, key); !math.IsInf(score, -1) {
+		heap.Push(h, completionCandidate{n: n, key: key, score: score})
+	}
+	for h.Len() > 0 && len(results) < k {
+		c := heap.Pop(h).(completionCandidate)
+		if c.n == nil {
+			results = append(results, Completion{Key: c.key, Value: c.value, Score: c.score})
+			continue
+		}
+		if c.n.hasValue() {
+			if score := scorer.fn(c.key, c.n.value); score > math.Inf(-1) {
+				heap.Push(h, completionCandidate{key: c.key, value: c.n.value, score: score})
+			}
+		}
+		for _, child := range c.n.children {
+			childKey := make([]byte, 0, len(c.key)+len(child.label))
+			childKey = append(append(childKey, c.key...), child.label...)
+			if score := t.maxScore(child, childKey); !math.IsInf(score, -1) {
+				heap.Push(h, completionCandidate{n: child, key: childKey, score: score})
+			}
+		}
+	}
+	return results
+}
+
+// maxScore returns the maximum score in the subtree rooted at n, or
+// negative infinity if there are no keys with scores. key must be the
+// key of n, and its backing store is reused for descendants.
+func (t *Tree) maxScore(n *node, key []byte) float64 {
+	if n.scoreEpoch == t.scoreEpoch {
+		return n.maxScore
+	}
+	max := math.Inf(-1)
+	if n.hasValue() {
+		if score := t.scorer.fn(key, n.value); score > max {
+			max = score
+		}
+	}
+	for _, child := range n.children {
+		if score := t.maxScore(child, append(key, child.label...)); score > max {
+			max = score
+		}
+	}
+	n.maxScore = max
+	n.scoreEpoch = t.scoreEpoch
+	return max
+}
+
+// completionCandidate is a subtree rooted at n whose maximum score is
+// score, or a key with score if n is nil.
+type completionCandidate struct {
+	n     *node
+	key   []byte
+	value interface{}
+	score float64
+}
+
+// completionHeap is a max-heap of candidates by scores. Candidates with
+// the same score are ordered by keys, which are less than or equal to
+// keys in their subtrees.
+type completionHeap []completionCandidate
+
+func (h completionHeap) Len() int { return len(h) }
+
+func (h completionHeap) Less(i, j int) bool {
+	if h[i].score != h[j].score {
+		return h[i].score > h[j].score
+	}
+	return bytes.Compare(h[i].key, h[j].key) < 0
+}
+
+func (h completionHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *completionHeap) Push(x interface{}) {
+	*h = append(*h, x.(completionCandidate))
+}
+
+func (h *completionHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
@@ -0,0 +1,182 @@
+package radixtree
+
+import "sort"
+
+// Walk calls fn for each key in the radix tree and its value in
+// lexicographic order of keys. The key passed to fn is only valid until
+// fn returns and must not be modified. Walk stops when fn returns false.
+// fn must not modify the radix tree.
+func (t *Tree) Walk(fn func(key []byte, value interface{}) bool) {
+	t.root.walk(make([]byte, 0, 64), fn)
+}
+
+// WalkPrefix calls fn for each key which has the specified prefix and
+// its value in lexicographic order of keys, like Walk.
+func (t *Tree) WalkPrefix(prefix []byte, fn func(key []byte, value interface{}) bool) {
+	if n, key := t.subtree(prefix); n != nil {
+		n.walk(key, fn)
+	}
+}
+
+// WalkReverse calls fn for each key in the radix tree and its value in
+// reverse lexicographic order of keys, like Walk in the other direction.
+func (t *Tree) WalkReverse(fn func(key []byte, value interface{}) bool) {
+	t.root.walkReverse(make([]byte, 0, 64), fn)
+}
+
+// WalkPrefixReverse calls fn for each key which has the specified prefix
+// and its value in reverse lexicographic order of keys, like WalkReverse.
+func (t *Tree) WalkPrefixReverse(prefix []byte, fn func(key []byte, value interface{}) bool) {
+	if n, key := t.subtree(prefix); n != nil {
+		n.walkReverse(key, fn)
+	}
+}
+
+// walkReverse is the reverse of walk. It visits children from the last
+// to the first, and then the value of n, since the key of n is less
+// than the keys of its descendants.
+func (n *node) walkReverse(key []byte, fn func(key []byte, value interface{}) bool) bool {
+	for i := len(n.children) - 1; i >= 0; i-- {
+		child := n.children[i]
+		if !child.walkReverse(append(key, child.label...), fn) {
+			return false
+		}
+	}
+	if n.hasValue() {
+		return fn(key, n.value)
+	}
+	return true
+}
+
+// RangeOptions is the options for WalkRangeWithOptions.
+type RangeOptions struct {
+	// StartExclusive excludes start from the range.
+	StartExclusive bool
+
+	// EndInclusive includes end in the range.
+	EndInclusive bool
+
+	// Reverse makes keys visited in reverse lexicographic order.
+	Reverse bool
+}
+
+// WalkRange calls fn for each key in the interval [start, end) and its
+// value in lexicographic order of keys, like Walk. A nil start or end
+// means the interval is not bounded on that side.
+func (t *Tree) WalkRange(start, end []byte, fn func(key []byte, value interface{}) bool) {
+	t.WalkRangeWithOptions(start, end, RangeOptions{}, fn)
+}
+
+// WalkRangeReverse calls fn for each key in the interval [start, end)
+// and its value in reverse lexicographic order of keys, like WalkRange.
+func (t *Tree) WalkRangeReverse(start, end []byte, fn func(key []byte, value interface{}) bool) {
+	t.WalkRangeWithOptions(start, end, RangeOptions{Reverse: true}, fn)
+}
+
+// WalkRangeWithOptions calls fn for each key between start and end and
+// its value, like WalkRange, with bounds and order specified by opts.
+//
+// Subtrees whose keys are all out of the range are skipped without
+// being visited. Since children are sorted, they are found by comparing
+// the labels of children with the bounds on the path to the bounds.
+func (t *Tree) WalkRangeWithOptions(start, end []byte, opts RangeOptions, fn func(key []byte, value interface{}) bool) {
+	w := rangeWalker{start: start, end: end, opts: opts, fn: fn}
+	w.walk(&t.root, make([]byte, 0, 64), start != nil, end != nil)
+}
+
+type rangeWalker struct {
+	start, end []byte
+	opts       RangeOptions
+	fn         func(key []byte, value interface{}) bool
+}
+
+// walk visits keys in the range in the subtree rooted at n. key must be
+// the key of n. lower and upper tell whether key is a prefix of start
+// and end respectively, in which case the bound must be checked in the
+// subtree. Otherwise all keys in the subtree are inside of the bound.
+func (w *rangeWalker) walk(n *node, key []byte, lower, upper bool) bool {
+	visit := n.hasValue()
+	if lower && (len(key) < len(w.start) || w.opts.StartExclusive) {
+		visit = false
+	}
+	if upper && len(key) == len(w.end) && !w.opts.EndInclusive {
+		visit = false
+	}
+
+	lo, hi := n.childrenInRange(len(key), w.start, w.end, lower, upper)
+	if visit && !w.opts.Reverse && !w.fn(key, n.value) {
+		return false
+	}
+	for j := lo; j < hi; j++ {
+		i := j
+		if w.opts.Reverse {
+			i = lo + hi - 1 - j
+		}
+		child := n.children[i]
+		childLower, childUpper := false, false
+		if lower && i == lo && len(key) < len(w.start) {
+			var skip bool
+			if childLower, skip = boundState(child.label, w.start[len(key):], -1); skip {
+				continue
+			}
+		}
+		if upper && i == hi-1 {
+			var skip bool
+			if childUpper, skip = boundState(child.label, w.end[len(key):], 1); skip {
+				continue
+			}
+		}
+		if !w.walk(child, append(key, child.label...), childLower, childUpper) {
+			return false
+		}
+	}
+	if visit && w.opts.Reverse {
+		return w.fn(key, n.value)
+	}
+	return true
+}
+
+// childrenInRange returns the indexes of children of n which may have
+// keys between start and end, which are children[lo:hi]. keyLen is the
+// length of the key of n, and lower and upper are the ones for walk of
+// rangeWalker. Only the first and the last of them may have keys out of
+// the range, which can be checked with boundState.
+func (n *node) childrenInRange(keyLen int, start, end []byte, lower, upper bool) (lo, hi int) {
+	lo, hi = 0, len(n.children)
+	if lower && keyLen < len(start) {
+		lo = n.indexForPrefix(start[keyLen:])
+	}
+	if upper {
+		if keyLen == len(end) {
+			return lo, lo
+		}
+		c := end[keyLen]
+		hi = sort.Search(len(n.children), func(i int) bool {
+			return n.children[i].label[0] > c
+		})
+		if hi < lo {
+			hi = lo
+		}
+	}
+	return lo, hi
+}
+
+// boundState compares the keys in the subtree of a child whose label is
+// label with a bound, where rest is the bound after the key of the
+// parent. dir is -1 for the lower bound and 1 for the upper bound.
+// It returns whether the bound must be checked in the subtree, and
+// whether all keys in the subtree are out of the bound.
+func boundState(label, rest []byte, dir int) (active, skip bool) {
+	l := commonPrefixLength(label, rest)
+	switch {
+	case l == len(label):
+		return true, false
+	case l == len(rest):
+		// All keys in the subtree are greater than the bound.
+		return false, dir > 0
+	case label[l] < rest[l]:
+		return false, dir < 0
+	default:
+		return false, dir > 0
+	}
+}
@@ -0,0 +1,133 @@
+package radixtree
+
+import "strconv"
+
+// Op is the kind of a change made to a radix tree.
+type Op int
+
+const (
+	// OpSet is the operation for Set.
+	OpSet Op = iota + 1
+	// OpDelete is the operation for Delete.
+	OpDelete
+	// OpDeleteSubtree is the operation for DeleteSubtree.
+	OpDeleteSubtree
+)
+
+// String returns the name of the operation.
+func (op Op) String() string {
+	switch op {
+	case OpSet:
+		return "Set"
+	case OpDelete:
+		return "Delete"
+	case OpDeleteSubtree:
+		return "DeleteSubtree"
+	default:
+		return "Op(" + strconv.Itoa(int(op)) + ")"
+	}
+}
+
+// WatchFunc is the type of the function called for changes in a radix tree.
+// key is the key passed to Set or Delete, the prefix passed to
+// DeleteSubtree, or each key deleted by DeleteRange with OpDelete. It must
+// not be modified nor retained after the function returns.
+type WatchFunc func(op Op, key []byte)
+
+type watcher struct {
+	fn        WatchFunc
+	cancelled bool
+}
+
+// Watch registers fn to be called for changes which affect keys under
+// the specified prefix. fn is called synchronously after Set, after
+// Delete which deleted the key, after DeleteSubtree which deleted keys
+// under the watched prefix, and after DeleteRange for each deleted key.
+//
+// Watchers are kept in a radix tree of their prefixes, so finding the
+// watchers for a change costs the depth of the path to the changed key,
+// not the number of registered watchers.
+//
+// Call the returned cancel function to stop watching. It is safe to call
+// cancel more than once and to call it from fn.
+func (t *Tree) Watch(prefix []byte, fn WatchFunc) (cancel func()) {
+	if t.watchers == nil {
+		t.watchers = New()
+	}
+	w := &watcher{fn: fn}
+	var ws []*watcher
+	if v, ok := t.watchers.Get(prefix); ok {
+		ws = v.([]*watcher)
+	}
+	t.watchers.Set(prefix, append(ws, w))
+
+	key := append([]byte{}, prefix...)
+	return func() {
+		if w.cancelled {
+			return
+		}
+		w.cancelled = true
+		t.unwatch(key, w)
+	}
+}
+
+func (t *Tree) unwatch(prefix []byte, w *watcher) {
+	v, ok := t.watchers.Get(prefix)
+	if !ok {
+		return
+	}
+	ws := v.([]*watcher)
+	// Build a new slice since notify may be iterating the current one.
+	rest := make([]*watcher, 0, len(ws)-1)
+	for _, w2 := range ws {
+		if w2 != w {
+			rest = append(rest, w2)
+		}
+	}
+	if len(rest) > 0 {
+		t.watchers.Set(prefix, rest)
+	} else {
+		t.watchers.Delete(prefix)
+	}
+}
+
+// notify calls watchers for a change to key, whose prefixes are
+// prefixes of key, and then nested, which must be the watchers returned
+// from nestedWatchers before an OpDeleteSubtree change is made.
+func (t *Tree) notify(op Op, key []byte, nested []*watcher) {
+	if t.watchers == nil {
+		return
+	}
+	var targets []*watcher
+	t.watchers.walkPath(key, func(value interface{}) {
+		targets = append(targets, value.([]*watcher)...)
+	})
+	targets = append(targets, nested...)
+	for _, w := range targets {
+		if !w.cancelled {
+			w.fn(op, key)
+		}
+	}
+}
+
+// nestedWatchers returns watchers of prefixes which are longer than and
+// start with prefix, and which have keys under them. It must be called
+// before deleting the subtree for prefix, so that watchers of prefixes
+// without keys are not notified of the deletion.
+func (t *Tree) nestedWatchers(prefix []byte) []*watcher {
+	if t.watchers == nil {
+		return nil
+	}
+	n, nkey := t.watchers.subtree(prefix)
+	if n == nil {
+		return nil
+	}
+	var nested []*watcher
+	n.walk(nkey, func(k []byte, value interface{}) bool {
+		if len(k) > len(prefix) && t.CountPrefix(k) > 0 {
+			nested = append(nested, value.([]*watcher)...)
+		}
+		return true
+	})
+	return nested
+}
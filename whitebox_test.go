@@ -1,6 +1,8 @@
 package radixtree
 
 import (
+	"errors"
+	"math/rand"
 	"testing"
 )
 
@@ -101,3 +103,151 @@ func TestCommonPrefixLength(t *testing.T) {
 		}
 	}
 }
+
+func TestUnmarshalBinaryInvalidStructure(t *testing.T) {
+	testCases := []Tree{
+		{
+			root: node{
+				value: noValue,
+				children: []*node{
+					&node{label: []byte("water"), value: 1},
+					&node{label: []byte("tea"), value: 2},
+				},
+			},
+		},
+		{
+			root: node{
+				value: noValue,
+				children: []*node{
+					&node{
+						label: []byte("te"),
+						value: noValue,
+						children: []*node{
+							&node{label: []byte("a"), value: 1},
+						},
+					},
+				},
+			},
+		},
+		{
+			root: node{
+				value: noValue,
+				children: []*node{
+					&node{value: 1},
+				},
+			},
+		},
+	}
+	for i, tc := range testCases {
+		data, err := tc.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := New().UnmarshalBinary(data); !errors.Is(err, ErrInvalidBinary) {
+			t.Errorf("unexpected error, caseIndex=%d, err=%v", i, err)
+		}
+	}
+}
+
+func TestBitVector(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	var b bitVectorBuilder
+	var bits []bool
+	for i := 0; i < 1000; i++ {
+		bit := rnd.Intn(3) == 0
+		b.append(bit)
+		bits = append(bits, bit)
+	}
+	v := b.build()
+	ones, zeros := 0, 0
+	for i, bit := range bits {
+		if got := v.get(i); got != bit {
+			t.Errorf("get unmatch, i=%d, got=%v, want=%v", i, got, bit)
+		}
+		if got := v.rank1(i); got != ones {
+			t.Errorf("rank1 unmatch, i=%d, got=%d, want=%d", i, got, ones)
+		}
+		if bit {
+			ones++
+			if got := v.select1(ones); got != i {
+				t.Errorf("select1 unmatch, k=%d, got=%d, want=%d", ones, got, i)
+			}
+		} else {
+			zeros++
+			if got := v.select0(zeros); got != i {
+				t.Errorf("select0 unmatch, k=%d, got=%d, want=%d", zeros, got, i)
+			}
+		}
+	}
+}
+
+func TestNodeCount(t *testing.T) {
+	var check func(n *node) int
+	check = func(n *node) int {
+		count := 0
+		if n.hasValue() {
+			count++
+		}
+		for _, child := range n.children {
+			count += check(child)
+		}
+		if n.count != count {
+			t.Errorf("count unmatch, node=%q, got=%d, want=%d", n.label, n.count, count)
+		}
+		return count
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	randomKey := func() []byte {
+		b := make([]byte, rnd.Intn(6))
+		for j := range b {
+			b[j] = "abc"[rnd.Intn(3)]
+		}
+		return b
+	}
+	tree := New()
+	for i := 0; i < 2000; i++ {
+		switch rnd.Intn(8) {
+		case 0:
+			tree.Delete(randomKey())
+		case 1:
+			key := randomKey()
+			tree.DeleteSubtree(key[:len(key)/2])
+		case 2:
+			if rnd.Intn(10) == 0 {
+				tree.DeleteRange(randomKey(), randomKey())
+			}
+		default:
+			tree.Set(randomKey(), i)
+		}
+		check(&tree.root)
+	}
+
+	data, err := tree.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := New()
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	check(&decoded.root)
+
+	var keys [][]byte
+	tree.Walk(func(key []byte, value interface{}) bool {
+		keys = append(keys, append([]byte{}, key...))
+		return true
+	})
+	built, err := BuildSorted(func() ([]byte, interface{}, bool) {
+		if len(keys) == 0 {
+			return nil, nil, false
+		}
+		key := keys[0]
+		keys = keys[1:]
+		return key, nil, true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(&built.root)
+}